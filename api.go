@@ -0,0 +1,63 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/prometheus/common/log"
+	"net/http"
+	"strings"
+)
+
+// writeJSON sends v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debugf("Could not write response: %v", err)
+	}
+}
+
+// writeError sends an error message as a JSON response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// authorized checks the bearer token of requests that change the exporter
+// state. When no --web.admin-token is set the admin API is disabled and
+// every request is refused.
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	if *adminToken == "" {
+		writeError(w, http.StatusForbidden, "admin API disabled, set --web.admin-token to enable it")
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(*adminToken)) == 1 {
+		return true
+	}
+	writeError(w, http.StatusUnauthorized, "invalid or missing admin token")
+	return false
+}
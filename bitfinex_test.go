@@ -0,0 +1,51 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScrapeBitfinex(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[["tIOTUSD",1.01,100,1.02,200,0.01,0.01,1.015,1500000,1.1,0.9],` +
+			`["tBTCUSD",6400,1,6401,2,10,0.0016,6400.5,12000,6500,6300]]`))
+	}))
+	defer server.Close()
+
+	defer func(url string) { bitfinexURL = url }(bitfinexURL)
+	bitfinexURL = server.URL
+
+	e := newExporter("")
+	scrapeBitfinex(e)
+
+	checkGolden(t, "bitfinex", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectBitfinex(e, ch)
+	})))
+}
@@ -0,0 +1,313 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"container/list"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"sync"
+	"time"
+)
+
+// bundle is a bundle being assembled from the tx messages of its
+// transactions. Reattached transactions share the bundle hash, so a bundle
+// may hold several transactions for the same index. Every reattachment adds
+// a tail (index 0) transaction with a different trunk and branch.
+type bundle struct {
+	hash      string
+	firstSeen time.Time
+	size      int64
+	hasValue  bool
+	txs       map[string]int64
+	tails     map[string]bool
+	indices   map[int64]bool
+	confirmed map[int64]bool
+	complete  bool
+	element   *list.Element
+}
+
+// bundleTracker follows bundles from their first transaction until all their
+// transactions are confirmed. At most max bundles are tracked; the oldest is
+// dropped when a new one arrives, and bundles are dropped after timeout.
+// Zero value transactions approving the tail of a tracked value bundle that
+// is not confirmed yet are counted as promotions.
+type bundleTracker struct {
+	sync.Mutex
+	max     int
+	timeout time.Duration
+	bundles map[string]*bundle
+	txs     map[string]*bundle
+	order   *list.List
+
+	size          prometheus.Histogram
+	total         *prometheus.CounterVec
+	confirmTime   *prometheus.HistogramVec
+	reattachments prometheus.Counter
+	promotions    prometheus.Counter
+	reattachHisto prometheus.Histogram
+}
+
+func newBundleTracker(e *exporter, max int, timeout time.Duration) *bundleTracker {
+	return &bundleTracker{
+		max:           max,
+		timeout:       timeout,
+		bundles:       make(map[string]*bundle),
+		txs:           make(map[string]*bundle),
+		order:         list.New(),
+		size:          e.iotaZmqBundleSize,
+		total:         e.iotaZmqBundles,
+		confirmTime:   e.iotaZmqBundleConfirmTime,
+		reattachments: e.iotaZmqReattachments,
+		promotions:    e.iotaZmqPromotions,
+		reattachHisto: e.iotaZmqBundleReattachments,
+	}
+}
+
+func metricsBundles(e *exporter) {
+
+	e.iotaZmqBundleSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_bundle_size",
+			Name:    "iota_zmq_bundle_size",
+			Help:    "Number of transactions in the bundles seen by zeroMQ.",
+			Buckets: []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20, 30, 50},
+		})
+
+	e.iotaZmqBundles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_bundles_total",
+			Name: "iota_zmq_bundles_total",
+			Help: "Bundles of which all transactions were seen (complete) or that were dropped before that (incomplete).",
+		},
+		[]string{"state"},
+	)
+
+	confirmBuckets, err := parseBuckets(*zmqConfirmBuckets)
+	if err != nil {
+		log.Fatalf("Invalid --zmq.confirm-buckets: %v", err)
+	}
+
+	e.iotaZmqBundleConfirmTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_bundle_confirm_time",
+			Name:    "iota_zmq_bundle_confirm_time",
+			Help:    "Seconds from seeing the first tx of a bundle until all its txs are confirmed.",
+			Buckets: confirmBuckets,
+		},
+		[]string{"hasValue"},
+	)
+
+	e.iotaZmqBundlesTracked = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_bundles_tracked",
+			Name: "iota_zmq_bundles_tracked",
+			Help: "Bundles waiting for all their transactions to be seen or confirmed.",
+		})
+
+	e.iotaZmqReattachments = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_reattachments_total",
+			Name: "iota_zmq_reattachments_total",
+			Help: "Tail transactions seen for a bundle that was already attached to the tangle.",
+		})
+
+	e.iotaZmqPromotions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_promotions_total",
+			Name: "iota_zmq_promotions_total",
+			Help: "Zero value transactions approving the tail of a value bundle that is not confirmed yet.",
+		})
+
+	e.iotaZmqBundleReattachments = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_bundle_reattachments",
+			Name:    "iota_zmq_bundle_reattachments",
+			Help:    "Reattachments seen for a bundle before it was confirmed.",
+			Buckets: []float64{0, 1, 2, 3, 5, 10, 20},
+		})
+}
+
+func describeBundles(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaZmqBundleSize.Desc()
+	e.iotaZmqBundles.Describe(ch)
+	e.iotaZmqBundleConfirmTime.Describe(ch)
+	ch <- e.iotaZmqBundlesTracked.Desc()
+	ch <- e.iotaZmqReattachments.Desc()
+	ch <- e.iotaZmqPromotions.Desc()
+	ch <- e.iotaZmqBundleReattachments.Desc()
+}
+
+func collectBundles(e *exporter, ch chan<- prometheus.Metric) {
+	ch <- e.iotaZmqBundleSize
+	e.iotaZmqBundles.Collect(ch)
+	e.iotaZmqBundleConfirmTime.Collect(ch)
+	ch <- e.iotaZmqBundlesTracked
+	ch <- e.iotaZmqReattachments
+	ch <- e.iotaZmqPromotions
+	ch <- e.iotaZmqBundleReattachments
+}
+
+func scrapeBundles(e *exporter) {
+	if e.bundles == nil {
+		return
+	}
+	e.iotaZmqBundlesTracked.Set(float64(e.bundles.expire(time.Now())))
+}
+
+// seen adds a transaction to its bundle.
+func (bt *bundleTracker) seen(tx *transaction, now time.Time) {
+	bt.Lock()
+	defer bt.Unlock()
+
+	bt.promotion(tx)
+
+	b, ok := bt.bundles[tx.Bundle]
+	if !ok {
+		if bt.max > 0 && len(bt.bundles) >= bt.max {
+			bt.drop(bt.order.Front().Value.(*bundle))
+		}
+		b = &bundle{
+			hash:      tx.Bundle,
+			firstSeen: now,
+			size:      stoi(tx.LastIndex) + 1,
+			txs:       make(map[string]int64),
+			tails:     make(map[string]bool),
+			indices:   make(map[int64]bool),
+			confirmed: make(map[int64]bool),
+		}
+		b.element = bt.order.PushBack(b)
+		bt.bundles[tx.Bundle] = b
+		bt.size.Observe(float64(b.size))
+	}
+
+	index := stoi(tx.CurrentIndex)
+	if _, ok := b.txs[tx.Hash]; ok {
+		return
+	}
+	b.txs[tx.Hash] = index
+	bt.txs[tx.Hash] = b
+	if index == 0 {
+		if len(b.tails) > 0 {
+			bt.reattachments.Inc()
+		}
+		b.tails[tx.Hash] = true
+	}
+	b.indices[index] = true
+	b.hasValue = b.hasValue || tx.Value != 0
+
+	if !b.complete && int64(len(b.indices)) >= b.size {
+		b.complete = true
+		bt.total.WithLabelValues("complete").Inc()
+	}
+}
+
+// confirmed marks a transaction of a bundle as confirmed. Once every index of
+// the bundle has a confirmed transaction the bundle confirmation time is
+// measured and the bundle is no longer tracked.
+func (bt *bundleTracker) confirmed(sn *sn, now time.Time) {
+	bt.Lock()
+	defer bt.Unlock()
+
+	b, ok := bt.bundles[sn.Bundle]
+	if !ok {
+		return
+	}
+	index, ok := b.txs[sn.Hash]
+	if !ok {
+		return
+	}
+
+	b.confirmed[index] = true
+	if int64(len(b.confirmed)) >= b.size {
+		bt.confirmTime.WithLabelValues(getTxLabel(btoi64(b.hasValue))).Observe(now.Sub(b.firstSeen).Seconds())
+		if len(b.tails) > 0 {
+			bt.reattachHisto.Observe(float64(len(b.tails) - 1))
+		}
+		bt.remove(b)
+	}
+}
+
+// promotion counts a zero value transaction of another bundle approving the
+// tail of a pending value bundle. As soon as a transaction of a bundle is
+// confirmed, one of its attachments is, and promoting it is pointless.
+func (bt *bundleTracker) promotion(tx *transaction) {
+	if tx.Value != 0 {
+		return
+	}
+	for _, approved := range []string{tx.Trunk, tx.Branch} {
+		b, ok := bt.txs[approved]
+		if ok && b.hash != tx.Bundle && b.tails[approved] && b.hasValue && len(b.confirmed) == 0 {
+			bt.promotions.Inc()
+			return
+		}
+	}
+}
+
+// expire drops the bundles that were tracked for longer than the timeout and
+// returns the number of bundles still tracked.
+func (bt *bundleTracker) expire(now time.Time) int {
+	bt.Lock()
+	defer bt.Unlock()
+
+	for el := bt.order.Front(); el != nil; el = bt.order.Front() {
+		b := el.Value.(*bundle)
+		if now.Sub(b.firstSeen) < bt.timeout {
+			break
+		}
+		bt.drop(b)
+	}
+	return len(bt.bundles)
+}
+
+// drop stops tracking a bundle before it was confirmed.
+func (bt *bundleTracker) drop(b *bundle) {
+	if !b.complete {
+		bt.total.WithLabelValues("incomplete").Inc()
+	}
+	bt.remove(b)
+}
+
+func (bt *bundleTracker) remove(b *bundle) {
+	bt.order.Remove(b.element)
+	delete(bt.bundles, b.hash)
+	for hash := range b.txs {
+		delete(bt.txs, hash)
+	}
+}
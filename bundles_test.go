@@ -0,0 +1,195 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundleTracker(t *testing.T) {
+
+	e := newExporter("")
+	bt := newBundleTracker(e, 10, time.Hour)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// A value bundle of three transactions, of which the second is reattached
+	for i, hash := range []string{"TXA", "TXB", "TXC", "TXB2"} {
+		index := []string{"0", "1", "2", "1"}[i]
+		bt.seen(&transaction{Hash: hash, Bundle: "BUNDLE", Value: 1, CurrentIndex: index, LastIndex: "2"}, start)
+	}
+	// A zero value bundle that never completes
+	bt.seen(&transaction{Hash: "TXD", Bundle: "SPAM", CurrentIndex: "0", LastIndex: "1"}, start.Add(time.Minute))
+
+	if c := testutil.ToFloat64(e.iotaZmqBundles.WithLabelValues("complete")); c != 1 {
+		t.Errorf("Expected 1 complete bundle, got %v", c)
+	}
+
+	bt.confirmed(&sn{Hash: "TXA", Bundle: "BUNDLE"}, start.Add(10*time.Minute))
+	bt.confirmed(&sn{Hash: "TXB2", Bundle: "BUNDLE"}, start.Add(10*time.Minute))
+	if n := bt.expire(start.Add(10 * time.Minute)); n != 2 {
+		t.Errorf("Expected 2 tracked bundles, got %v", n)
+	}
+	bt.confirmed(&sn{Hash: "TXC", Bundle: "BUNDLE"}, start.Add(20*time.Minute))
+	if n := bt.expire(start.Add(20 * time.Minute)); n != 1 {
+		t.Errorf("Expected 1 tracked bundle after confirmation, got %v", n)
+	}
+
+	if n := bt.expire(start.Add(2 * time.Hour)); n != 0 {
+		t.Errorf("Expected no tracked bundles after the timeout, got %v", n)
+	}
+	if c := testutil.ToFloat64(e.iotaZmqBundles.WithLabelValues("incomplete")); c != 1 {
+		t.Errorf("Expected 1 incomplete bundle, got %v", c)
+	}
+
+	expected := `
+		# HELP iota_zmq_bundle_size Number of transactions in the bundles seen by zeroMQ.
+		# TYPE iota_zmq_bundle_size histogram
+		iota_zmq_bundle_size_bucket{le="1"} 0
+		iota_zmq_bundle_size_bucket{le="2"} 1
+		iota_zmq_bundle_size_bucket{le="3"} 2
+		iota_zmq_bundle_size_bucket{le="4"} 2
+		iota_zmq_bundle_size_bucket{le="5"} 2
+		iota_zmq_bundle_size_bucket{le="6"} 2
+		iota_zmq_bundle_size_bucket{le="8"} 2
+		iota_zmq_bundle_size_bucket{le="10"} 2
+		iota_zmq_bundle_size_bucket{le="15"} 2
+		iota_zmq_bundle_size_bucket{le="20"} 2
+		iota_zmq_bundle_size_bucket{le="30"} 2
+		iota_zmq_bundle_size_bucket{le="50"} 2
+		iota_zmq_bundle_size_bucket{le="+Inf"} 2
+		iota_zmq_bundle_size_sum 5
+		iota_zmq_bundle_size_count 2
+	`
+	if err := testutil.CollectAndCompare(e.iotaZmqBundleSize, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBundleReattachments(t *testing.T) {
+
+	e := newExporter("")
+	bt := newBundleTracker(e, 10, time.Hour)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// A single transaction bundle attached three times
+	for i, hash := range []string{"TAIL1", "TAIL2", "TAIL3"} {
+		bt.seen(&transaction{Hash: hash, Bundle: "BUNDLE", Value: 1, CurrentIndex: "0", LastIndex: "0", Trunk: "T" + hash}, start)
+		if i == 0 {
+			// A promotion approving the first tail, and a zero value tx of the bundle itself
+			bt.seen(&transaction{Hash: "PROMOTE", Bundle: "PROMOTION", CurrentIndex: "0", LastIndex: "0", Trunk: "TAIL1", Branch: "TIP"}, start)
+		}
+	}
+	bt.seen(&transaction{Hash: "TAIL1", Bundle: "BUNDLE", Value: 1, CurrentIndex: "0", LastIndex: "0"}, start)
+
+	if c := testutil.ToFloat64(e.iotaZmqReattachments); c != 2 {
+		t.Errorf("Expected 2 reattachments, got %v", c)
+	}
+	if c := testutil.ToFloat64(e.iotaZmqPromotions); c != 1 {
+		t.Errorf("Expected 1 promotion, got %v", c)
+	}
+
+	bt.confirmed(&sn{Hash: "TAIL2", Bundle: "BUNDLE"}, start.Add(time.Minute))
+	if _, ok := bt.txs["TAIL1"]; ok {
+		t.Errorf("Expected the transactions of a confirmed bundle to be forgotten")
+	}
+
+	expected := `
+		# HELP iota_zmq_bundle_reattachments Reattachments seen for a bundle before it was confirmed.
+		# TYPE iota_zmq_bundle_reattachments histogram
+		iota_zmq_bundle_reattachments_bucket{le="0"} 0
+		iota_zmq_bundle_reattachments_bucket{le="1"} 0
+		iota_zmq_bundle_reattachments_bucket{le="2"} 1
+		iota_zmq_bundle_reattachments_bucket{le="3"} 1
+		iota_zmq_bundle_reattachments_bucket{le="5"} 1
+		iota_zmq_bundle_reattachments_bucket{le="10"} 1
+		iota_zmq_bundle_reattachments_bucket{le="20"} 1
+		iota_zmq_bundle_reattachments_bucket{le="+Inf"} 1
+		iota_zmq_bundle_reattachments_sum 2
+		iota_zmq_bundle_reattachments_count 1
+	`
+	if err := testutil.CollectAndCompare(e.iotaZmqBundleReattachments, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBundlePromotions(t *testing.T) {
+
+	e := newExporter("")
+	bt := newBundleTracker(e, 10, time.Hour)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// A two transaction value bundle and a zero value tip
+	bt.seen(&transaction{Hash: "TAIL", Bundle: "VALUE", Value: -5, CurrentIndex: "0", LastIndex: "1", Trunk: "HEAD"}, start)
+	bt.seen(&transaction{Hash: "HEAD", Bundle: "VALUE", Value: 5, CurrentIndex: "1", LastIndex: "1"}, start)
+	bt.seen(&transaction{Hash: "SPAM", Bundle: "SPAM", CurrentIndex: "0", LastIndex: "0"}, start)
+
+	for i, test := range []struct {
+		tx       transaction
+		expected float64
+	}{
+		{transaction{Hash: "A", Bundle: "A", CurrentIndex: "0", LastIndex: "0", Trunk: "SPAM", Branch: "SPAM"}, 0},
+		{transaction{Hash: "B", Bundle: "B", CurrentIndex: "0", LastIndex: "0", Trunk: "HEAD", Branch: "SPAM"}, 0},
+		{transaction{Hash: "C", Bundle: "C", Value: 1, CurrentIndex: "0", LastIndex: "0", Trunk: "TAIL"}, 0},
+		{transaction{Hash: "D", Bundle: "D", CurrentIndex: "0", LastIndex: "0", Trunk: "SPAM", Branch: "TAIL"}, 1},
+	} {
+		bt.seen(&test.tx, start)
+		if c := testutil.ToFloat64(e.iotaZmqPromotions); c != test.expected {
+			t.Errorf("Test %v: Expected %v promotions, got %v", i, test.expected, c)
+		}
+	}
+
+	// Promoting a bundle that is confirmed is pointless
+	bt.confirmed(&sn{Hash: "HEAD", Bundle: "VALUE"}, start)
+	bt.seen(&transaction{Hash: "E", Bundle: "E", CurrentIndex: "0", LastIndex: "0", Trunk: "TAIL"}, start)
+	if c := testutil.ToFloat64(e.iotaZmqPromotions); c != 1 {
+		t.Errorf("Expected no promotion of a confirmed bundle, got %v", c)
+	}
+}
+
+func TestCollectBundlesGolden(t *testing.T) {
+
+	e := newExporter("")
+	e.bundles = newBundleTracker(e, 10, time.Hour)
+	start := time.Now().Add(-30 * time.Minute)
+
+	// A value bundle that is reattached, promoted and confirmed, and a zero
+	// value bundle that is still tracked
+	for i, hash := range []string{"TXA", "TXB", "TXA2"} {
+		index := []string{"0", "1", "0"}[i]
+		e.bundles.seen(&transaction{Hash: hash, Bundle: "BUNDLE", Value: 10, CurrentIndex: index, LastIndex: "1"}, start)
+	}
+	e.bundles.seen(&transaction{Hash: "TXP", Bundle: "PROMOTE", Trunk: "TXA2", CurrentIndex: "0", LastIndex: "1"}, start.Add(time.Minute))
+	e.bundles.confirmed(&sn{Hash: "TXA2", Bundle: "BUNDLE"}, start.Add(10*time.Minute))
+	e.bundles.confirmed(&sn{Hash: "TXB", Bundle: "BUNDLE"}, start.Add(10*time.Minute))
+	scrapeBundles(e)
+
+	checkGolden(t, "bundles", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectBundles(e, ch)
+	})))
+}
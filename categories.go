@@ -0,0 +1,249 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// categoryOther is the category of transactions that match no rule, and of
+// tag categories beyond the cardinality cap.
+const categoryOther = "other"
+
+// categoryFromTag is used as the category of a rule to name the category
+// after the decoded tag of the transaction.
+const categoryFromTag = "$tag"
+
+// categoryRule maps transactions to a category. All the fields that are set
+// must match; the first matching rule wins.
+type categoryRule struct {
+	Category  string `json:"category"`
+	Tag       string `json:"tag,omitempty"`
+	TagTrytes string `json:"tagTrytes,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Value     string `json:"value,omitempty"`
+
+	address *regexp.Regexp
+}
+
+// categorizer classifies transactions by their tag and address. The
+// categories named in the rules are always exported; at most max categories
+// named after tags are, transactions of further tags are counted as other.
+type categorizer struct {
+	sync.Mutex
+	rules      []categoryRule
+	max        int
+	configured map[string]bool
+	categories map[string]bool
+}
+
+func newCategorizer(rules []categoryRule, max int) (*categorizer, error) {
+	configured := make(map[string]bool)
+	for i := range rules {
+		r := &rules[i]
+		if r.Category == "" {
+			return nil, fmt.Errorf("rule %d has no category", i+1)
+		}
+		if r.Value != "" && r.Value != "zero" && r.Value != "nonzero" {
+			return nil, fmt.Errorf("rule %d: value must be zero or nonzero", i+1)
+		}
+		if r.Address != "" {
+			re, err := regexp.Compile(r.Address)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i+1, err)
+			}
+			r.address = re
+		}
+		if r.Category != categoryFromTag {
+			configured[r.Category] = true
+		}
+	}
+	return &categorizer{rules: rules, max: max, configured: configured, categories: make(map[string]bool)}, nil
+}
+
+func loadCategorizer(file string, max int) (*categorizer, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules []categoryRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	c, err := newCategorizer(rules, max)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	log.Infof("Loaded %d category rules from %s.", len(rules), file)
+	return c, nil
+}
+
+func (r *categoryRule) matches(tx *transaction, tag string) bool {
+	if r.Tag != "" && !strings.HasPrefix(tag, r.Tag) {
+		return false
+	}
+	if r.TagTrytes != "" && !strings.HasPrefix(tx.Tag, r.TagTrytes) {
+		return false
+	}
+	if r.address != nil && !r.address.MatchString(tx.Address) {
+		return false
+	}
+	if r.Value == "zero" && tx.Value != 0 || r.Value == "nonzero" && tx.Value == 0 {
+		return false
+	}
+	return true
+}
+
+// classify returns the category of a transaction.
+func (c *categorizer) classify(tx *transaction) string {
+	tag := trytesToASCII(tx.Tag)
+	for i := range c.rules {
+		r := &c.rules[i]
+		if !r.matches(tx, tag) {
+			continue
+		}
+		if r.Category != categoryFromTag {
+			return r.Category
+		}
+		return c.admit(tag)
+	}
+	return categoryOther
+}
+
+// admit applies the cardinality cap to a category named after a tag.
+func (c *categorizer) admit(category string) string {
+	if category == "" {
+		return categoryOther
+	}
+	if c.configured[category] {
+		return category
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.categories[category] {
+		return category
+	}
+	if len(c.categories) >= c.max {
+		return categoryOther
+	}
+	c.categories[category] = true
+	return category
+}
+
+// trytesToASCII decodes a tag. Each character is encoded in two trytes;
+// decoding stops at the 9 padding or at the first character that is not
+// printable, so only the readable prefix of a tag is returned.
+func trytesToASCII(trytes string) string {
+	const alphabet = "9ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	var b strings.Builder
+	for i := 0; i+1 < len(trytes); i += 2 {
+		first := strings.IndexByte(alphabet, trytes[i])
+		second := strings.IndexByte(alphabet, trytes[i+1])
+		if first < 0 || second < 0 {
+			break
+		}
+		ch := first + second*27
+		if ch < 32 || ch > 126 {
+			break
+		}
+		b.WriteByte(byte(ch))
+	}
+	return b.String()
+}
+
+func initCategories(e *exporter) {
+	if *zmqCategoriesFile == "" {
+		return
+	}
+	c, err := loadCategorizer(*zmqCategoriesFile, *zmqCategoriesMax)
+	if err != nil {
+		log.Fatal(err)
+	}
+	e.categories = c
+}
+
+func metricsCategories(e *exporter) {
+
+	e.iotaZmqCategorySeen = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_category_seen_total",
+			Name: "iota_zmq_category_seen_total",
+			Help: "Transactions seen by zeroMQ, by category of the --zmq.categories-file rules.",
+		},
+		[]string{"category"},
+	)
+
+	e.iotaZmqCategoryConfirmed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_category_confirmed_total",
+			Name: "iota_zmq_category_confirmed_total",
+			Help: "Transactions confirmed by zeroMQ, by category of the --zmq.categories-file rules.",
+		},
+		[]string{"category"},
+	)
+
+	confirmBuckets, err := parseBuckets(*zmqConfirmBuckets)
+	if err != nil {
+		log.Fatalf("Invalid --zmq.confirm-buckets: %v", err)
+	}
+
+	e.iotaZmqCategoryConfirmTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_category_confirm_time",
+			Name:    "iota_zmq_category_confirm_time",
+			Help:    "Seconds from seeing a transaction until it is confirmed, by category.",
+			Buckets: confirmBuckets,
+		},
+		[]string{"category"},
+	)
+}
+
+func describeCategories(e *exporter, ch chan<- *prometheus.Desc) {
+	e.iotaZmqCategorySeen.Describe(ch)
+	e.iotaZmqCategoryConfirmed.Describe(ch)
+	e.iotaZmqCategoryConfirmTime.Describe(ch)
+}
+
+func collectCategories(e *exporter, ch chan<- prometheus.Metric) {
+	e.iotaZmqCategorySeen.Collect(ch)
+	e.iotaZmqCategoryConfirmed.Collect(ch)
+	e.iotaZmqCategoryConfirmTime.Collect(ch)
+}
@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+func TestTrytesToASCII(t *testing.T) {
+
+	for _, test := range []struct {
+		trytes, ascii string
+	}{
+		{"SBYBCCKB999999999999999999", "IOTA"},
+		{"999999999999999999999999999", ""},
+		{"SBYBCCKBZZ9999999999999999", "IOTA"},
+	} {
+		if ascii := trytesToASCII(test.trytes); ascii != test.ascii {
+			t.Errorf("Test %v: Expected %q, got %q", test.trytes, test.ascii, ascii)
+		}
+	}
+}
+
+func TestCategorizer(t *testing.T) {
+
+	c, err := newCategorizer([]categoryRule{
+		{Category: "promotion", TagTrytes: "PROMOTE", Value: "zero"},
+		{Category: "exchange", Address: "^EXCHANGE"},
+		{Category: categoryFromTag, Tag: "IO"},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		tx       transaction
+		category string
+	}{
+		{transaction{Tag: "SBYBCCKB9999"}, "IOTA"},
+		// The cap of 1 tag category is reached
+		{transaction{Tag: "SBYB99999999"}, categoryOther},
+		{transaction{Tag: "SBYBCCKBSB99"}, categoryOther},
+		{transaction{Tag: "SBYBCCKB9999"}, "IOTA"},
+		// Configured categories are not capped
+		{transaction{Tag: "PROMOTE9999"}, "promotion"},
+		{transaction{Tag: "PROMOTE9999", Value: 1}, categoryOther},
+		{transaction{Address: "EXCHANGE999"}, "exchange"},
+	} {
+		if category := c.classify(&test.tx); category != test.category {
+			t.Errorf("Test %+v: Expected category %v, got %v", test.tx, test.category, category)
+		}
+	}
+
+	if _, err := newCategorizer([]categoryRule{{Category: "bad", Address: "("}}, 3); err == nil {
+		t.Errorf("Expected an error for an invalid address pattern")
+	}
+}
+
+func TestCollectCategoriesGolden(t *testing.T) {
+
+	c, err := newCategorizer([]categoryRule{
+		{Category: "promotion", TagTrytes: "PROMOTE", Value: "zero"},
+		{Category: "exchange", Address: "^EXCHANGE"},
+		{Category: categoryFromTag, Tag: "IO"},
+	}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := newTestPipeline(t, newMemoryStore(1000, testRetention), 100, "drop-newest")
+	e.categories = c
+
+	for n, tx := range []struct {
+		tag, address string
+		value        int64
+	}{
+		{"PROMOTE9999", "ADDRESS9", 0},
+		{"PROMOTE9999", "ADDRESS9", 0},
+		{"SBYBCCKB9999", "ADDRESS9", 0},
+		{"TAG99999999", "EXCHANGE9", 100},
+		{"TAG99999999", "ADDRESS9", 0},
+	} {
+		handleZmqMessage(e, fmt.Sprintf("tx %s %s %d %s 0 0 0 %s %s %s 0", syntheticHash("TX", n), tx.address, tx.value,
+			tx.tag, syntheticHash("BUNDLE", n), syntheticHash("TX", n-1), syntheticHash("TX", n-2)))
+	}
+
+	zmqConfirmationLock.Lock()
+	zmqConfirmationSet = []zmqConfirmation{
+		{label: "0", duration: 240, category: "promotion"},
+		{label: "<> 0", duration: 900, category: "exchange"},
+	}
+	zmqConfirmationLock.Unlock()
+	drainZmqConfirmations(e)
+
+	checkGolden(t, "categories", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectCategories(e, ch)
+	})))
+}
@@ -0,0 +1,215 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// zmqCheckpointKey is the database metadata key of the ZMQ checkpoint.
+const zmqCheckpointKey = "zmq-accumulators"
+
+// zmqCheckpoint is the state of the ZMQ accumulators and confirmation
+// histograms as saved in the database, so the counts continue where they
+// left off after a restart.
+type zmqCheckpoint struct {
+	Saved             int64
+	TxTotal           float64
+	TxAnyZero         float64
+	TxAnyNotZero      float64
+	TxValue           float64
+	TxConfirmed       float64
+	ValueMoved        float64
+	ValueConfirmed    float64
+	ConfirmTime       map[string]histogramState
+	ConfirmMilestones map[string]histogramState
+}
+
+// histogramState is the state of a single histogram. Counts holds the number
+// of observations per bucket, not the cumulative counts.
+type histogramState struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
+// persistentHistogramVec is a histogram with a single label whose state can
+// be saved and restored, which the client library histograms do not allow.
+type persistentHistogramVec struct {
+	sync.Mutex
+	desc    *prometheus.Desc
+	buckets []float64
+	states  map[string]*histogramState
+}
+
+func newPersistentHistogramVec(name, help string, buckets []float64, label string) *persistentHistogramVec {
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	return &persistentHistogramVec{
+		desc:    prometheus.NewDesc(name, help, []string{label}, nil),
+		buckets: buckets,
+		states:  make(map[string]*histogramState),
+	}
+}
+
+func (h *persistentHistogramVec) observe(label string, v float64) {
+	h.Lock()
+	defer h.Unlock()
+
+	s, ok := h.states[label]
+	if !ok {
+		s = &histogramState{Buckets: h.buckets, Counts: make([]uint64, len(h.buckets))}
+		h.states[label] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.Counts[i]++
+			break
+		}
+	}
+	s.Count++
+	s.Sum += v
+}
+
+func (h *persistentHistogramVec) collect(ch chan<- prometheus.Metric) {
+	h.Lock()
+	defer h.Unlock()
+
+	for label, s := range h.states {
+		buckets := make(map[float64]uint64, len(s.Buckets))
+		var cumulative uint64
+		for i, upper := range s.Buckets {
+			cumulative += s.Counts[i]
+			buckets[upper] = cumulative
+		}
+		ch <- prometheus.MustNewConstHistogram(h.desc, s.Count, s.Sum, buckets, label)
+	}
+}
+
+func (h *persistentHistogramVec) snapshot() map[string]histogramState {
+	h.Lock()
+	defer h.Unlock()
+
+	states := make(map[string]histogramState, len(h.states))
+	for label, s := range h.states {
+		states[label] = histogramState{
+			Buckets: s.Buckets,
+			Counts:  append([]uint64(nil), s.Counts...),
+			Count:   s.Count,
+			Sum:     s.Sum,
+		}
+	}
+	return states
+}
+
+// restore replaces the state with a saved one. Saved states with different
+// buckets than the histogram are skipped, as they cannot be merged.
+func (h *persistentHistogramVec) restore(states map[string]histogramState) {
+	h.Lock()
+	defer h.Unlock()
+
+	for label, s := range states {
+		if !reflect.DeepEqual(s.Buckets, h.buckets) || len(s.Counts) != len(s.Buckets) {
+			log.Infof("Buckets of %s changed, not restoring its %q state.", h.desc, label)
+			continue
+		}
+		restored := s
+		h.states[label] = &restored
+	}
+}
+
+func saveZmqCheckpoint(e *exporter) error {
+
+	drainZmqConfirmations(e)
+
+	zmqAccumsLock.Lock()
+	cp := zmqCheckpoint{
+		Saved:          time.Now().Unix(),
+		TxTotal:        zmqAccums.txTotal,
+		TxAnyZero:      zmqAccums.txAnyZero,
+		TxAnyNotZero:   zmqAccums.txAnyNotZero,
+		TxValue:        zmqAccums.txValue,
+		TxConfirmed:    zmqAccums.txConfirmed,
+		ValueMoved:     zmqAccums.valueMoved,
+		ValueConfirmed: zmqAccums.valueConfirmed,
+	}
+	zmqAccumsLock.Unlock()
+	cp.ConfirmTime = e.iotaZmqConfirmationHisto.snapshot()
+	cp.ConfirmMilestones = e.iotaZmqConfirmationMilestonesHisto.snapshot()
+
+	val, err := json.Marshal(&cp)
+	if err != nil {
+		return err
+	}
+	return e.zmq.store.PutMeta(zmqCheckpointKey, val)
+}
+
+func restoreZmqCheckpoint(e *exporter) error {
+
+	val, err := e.zmq.store.GetMeta(zmqCheckpointKey)
+	if err == errMetaNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	cp := zmqCheckpoint{}
+	if err := json.Unmarshal(val, &cp); err != nil {
+		return err
+	}
+
+	zmqAccumsLock.Lock()
+	zmqAccums.txTotal = cp.TxTotal
+	zmqAccums.txAnyZero = cp.TxAnyZero
+	zmqAccums.txAnyNotZero = cp.TxAnyNotZero
+	zmqAccums.txValue = cp.TxValue
+	zmqAccums.txConfirmed = cp.TxConfirmed
+	zmqAccums.valueMoved = cp.ValueMoved
+	zmqAccums.valueConfirmed = cp.ValueConfirmed
+	zmqAccumsLock.Unlock()
+	e.iotaZmqConfirmationHisto.restore(cp.ConfirmTime)
+	e.iotaZmqConfirmationMilestonesHisto.restore(cp.ConfirmMilestones)
+
+	log.Infof("Restored ZMQ accumulators from %s: %v tx seen, %v confirmed.",
+		time.Unix(cp.Saved, 0).UTC().Format(time.RFC3339), cp.TxTotal, cp.TxConfirmed)
+	return nil
+}
+
+// checkpointZmq saves the ZMQ accumulators every interval.
+func checkpointZmq(e *exporter, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := saveZmqCheckpoint(e); err != nil {
+			log.Infof("Database error %v.", err)
+		}
+	}
+}
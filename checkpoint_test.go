@@ -0,0 +1,91 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestZmqCheckpoint(t *testing.T) {
+
+	store := newMemoryStore(10, testRetention)
+	e := newExporter("")
+	e.zmq = &zmqPipeline{store: store}
+
+	zmqAccums = zmqAccumsf{txTotal: 10, txAnyZero: 7, txAnyNotZero: 3, txValue: 3, txConfirmed: 5, txToProcess: 2, valueMoved: 1500, valueConfirmed: 500}
+	atomic.StoreInt64(&zmqLatestMilestone, 400000)
+	e.iotaZmqConfirmationHisto.observe("0", 250)
+	e.iotaZmqConfirmationHisto.observe("0", 5000)
+	e.iotaZmqConfirmationMilestonesHisto.observe("<> 0", 2)
+	saved := e.iotaZmqConfirmationHisto.snapshot()
+
+	if err := saveZmqCheckpoint(e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start over as after a restart
+	zmqAccums = zmqAccumsf{}
+	atomic.StoreInt64(&zmqLatestMilestone, 0)
+	e = newExporter("")
+	e.zmq = &zmqPipeline{store: store}
+
+	if err := restoreZmqCheckpoint(e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queue sizes come from the next rstat message and are not restored
+	expected := zmqAccumsf{txTotal: 10, txAnyZero: 7, txAnyNotZero: 3, txValue: 3, txConfirmed: 5, valueMoved: 1500, valueConfirmed: 500}
+	if zmqAccums != expected {
+		t.Errorf("Expected accumulators %v, got %v", expected, zmqAccums)
+	}
+	// The milestone may be hours old after a restart, so it stays unknown
+	// until the next sn or lmi message
+	if m := atomic.LoadInt64(&zmqLatestMilestone); m != 0 {
+		t.Errorf("Expected latest milestone 0, got %v", m)
+	}
+	if got := e.iotaZmqConfirmationHisto.snapshot(); !reflect.DeepEqual(got, saved) {
+		t.Errorf("Expected confirmation histogram %v, got %v", saved, got)
+	}
+	if got := e.iotaZmqConfirmationMilestonesHisto.snapshot()["<> 0"]; got.Count != 1 || got.Sum != 2 {
+		t.Errorf("Expected one restored milestone observation, got %v", got)
+	}
+
+	zmqAccums = zmqAccumsf{}
+	atomic.StoreInt64(&zmqLatestMilestone, 0)
+}
+
+func TestPersistentHistogramBucketChange(t *testing.T) {
+
+	h := newPersistentHistogramVec("test", "Test.", []float64{1, 2}, "label")
+	h.observe("a", 1.5)
+
+	other := newPersistentHistogramVec("test", "Test.", []float64{1, 2, 3}, "label")
+	other.restore(h.snapshot())
+	if len(other.snapshot()) != 0 {
+		t.Errorf("Expected state with other buckets to be skipped, got %v", other.snapshot())
+	}
+}
@@ -0,0 +1,225 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"container/list"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// conflictsKept is the number of recent conflicts listed by the API.
+const conflictsKept = 100
+
+// conflictBundle is one of the bundles spending from an address.
+type conflictBundle struct {
+	Bundle    string    `json:"bundle"`
+	Value     int64     `json:"value"`
+	FirstSeen time.Time `json:"firstSeen"`
+}
+
+// conflict is a set of distinct bundles spending from the same address. At
+// most one of them can be confirmed.
+type conflict struct {
+	Address   string           `json:"address"`
+	Detected  time.Time        `json:"detected"`
+	Bundles   []conflictBundle `json:"bundles"`
+	Confirmed string           `json:"confirmed,omitempty"`
+}
+
+// addressSpends holds the bundles spending from an address.
+type addressSpends struct {
+	address   string
+	bundles   []conflictBundle
+	confirmed string
+	lastSeen  time.Time
+	conflict  *conflict
+	element   *list.Element
+}
+
+// conflictDetector finds inputs of the same address in distinct bundles.
+// Reattachments share the bundle hash, so they are not conflicts. Spends
+// are forgotten after window and at most max addresses are tracked.
+type conflictDetector struct {
+	sync.Mutex
+	max     int
+	window  time.Duration
+	spends  map[string]*addressSpends
+	order   *list.List
+	recent  []*conflict
+	total   prometheus.Counter
+	settled prometheus.Counter
+}
+
+func newConflictDetector(e *exporter, max int, window time.Duration) *conflictDetector {
+	return &conflictDetector{
+		max:     max,
+		window:  window,
+		spends:  make(map[string]*addressSpends),
+		order:   list.New(),
+		total:   e.iotaZmqConflicts,
+		settled: e.iotaZmqConflictsConfirmed,
+	}
+}
+
+func metricsConflicts(e *exporter) {
+
+	e.iotaZmqConflicts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_conflicts_total",
+			Name: "iota_zmq_conflicts_total",
+			Help: "Addresses spent from in more than one distinct bundle (double spends).",
+		})
+
+	e.iotaZmqConflictsConfirmed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_conflicts_confirmed_total",
+			Name: "iota_zmq_conflicts_confirmed_total",
+			Help: "Conflicts of which one of the bundles was confirmed.",
+		})
+}
+
+func describeConflicts(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaZmqConflicts.Desc()
+	ch <- e.iotaZmqConflictsConfirmed.Desc()
+}
+
+func collectConflicts(e *exporter, ch chan<- prometheus.Metric) {
+	ch <- e.iotaZmqConflicts
+	ch <- e.iotaZmqConflictsConfirmed
+}
+
+// seen records the inputs of value bundles.
+func (cd *conflictDetector) seen(tx *transaction, now time.Time) {
+	if tx.Value >= 0 {
+		return
+	}
+
+	cd.Lock()
+	defer cd.Unlock()
+
+	cd.expire(now)
+
+	s, ok := cd.spends[tx.Address]
+	if !ok {
+		if cd.max > 0 && len(cd.spends) >= cd.max {
+			cd.remove(cd.order.Front().Value.(*addressSpends))
+		}
+		s = &addressSpends{address: tx.Address}
+		s.element = cd.order.PushBack(s)
+		cd.spends[tx.Address] = s
+	}
+	s.lastSeen = now
+	cd.order.MoveToBack(s.element)
+
+	for _, b := range s.bundles {
+		if b.Bundle == tx.Bundle {
+			return
+		}
+	}
+	s.bundles = append(s.bundles, conflictBundle{Bundle: tx.Bundle, Value: tx.Value, FirstSeen: now})
+	if len(s.bundles) < 2 {
+		return
+	}
+
+	if s.conflict == nil {
+		s.conflict = &conflict{Address: s.address, Detected: now, Confirmed: s.confirmed}
+		cd.total.Inc()
+		if s.confirmed != "" {
+			cd.settled.Inc()
+		}
+		cd.recent = append(cd.recent, s.conflict)
+		if len(cd.recent) > conflictsKept {
+			cd.recent = cd.recent[1:]
+		}
+	}
+	s.conflict.Bundles = append([]conflictBundle(nil), s.bundles...)
+}
+
+// confirmed records which bundle spending from an address was confirmed.
+func (cd *conflictDetector) confirmed(sn *sn) {
+	cd.Lock()
+	defer cd.Unlock()
+
+	s, ok := cd.spends[sn.AddressHash]
+	if !ok || s.confirmed != "" {
+		return
+	}
+	for _, b := range s.bundles {
+		if b.Bundle != sn.Bundle {
+			continue
+		}
+		s.confirmed = sn.Bundle
+		if s.conflict != nil {
+			s.conflict.Confirmed = sn.Bundle
+			cd.settled.Inc()
+		}
+		return
+	}
+}
+
+// expire forgets the addresses that were not spent from during the window.
+func (cd *conflictDetector) expire(now time.Time) {
+	for el := cd.order.Front(); el != nil; el = cd.order.Front() {
+		s := el.Value.(*addressSpends)
+		if now.Sub(s.lastSeen) < cd.window {
+			break
+		}
+		cd.remove(s)
+	}
+}
+
+func (cd *conflictDetector) remove(s *addressSpends) {
+	cd.order.Remove(s.element)
+	delete(cd.spends, s.address)
+}
+
+// list returns the recent conflicts, newest first.
+func (cd *conflictDetector) list() []conflict {
+	cd.Lock()
+	defer cd.Unlock()
+
+	list := make([]conflict, 0, len(cd.recent))
+	for i := len(cd.recent) - 1; i >= 0; i-- {
+		c := *cd.recent[i]
+		c.Bundles = append([]conflictBundle(nil), c.Bundles...)
+		list = append(list, c)
+	}
+	return list
+}
+
+func (cd *conflictDetector) handleConflicts(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, cd.list())
+}
+
+func initConflicts(e *exporter) {
+	e.conflicts = newConflictDetector(e, *zmqConflictMax, *zmqConflictWindow)
+}
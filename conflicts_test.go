@@ -0,0 +1,94 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConflictDetector(t *testing.T) {
+
+	e := newExporter("")
+	cd := newConflictDetector(e, 10, time.Hour)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// A reattachment of the same bundle is not a conflict
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B1", Value: -100}, start)
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B1", Value: -100}, start.Add(time.Minute))
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B1", Value: 100}, start.Add(time.Minute))
+	if c := testutil.ToFloat64(e.iotaZmqConflicts); c != 0 {
+		t.Errorf("Expected no conflicts for a reattachment, got %v", c)
+	}
+
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B2", Value: -100}, start.Add(2*time.Minute))
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B3", Value: -100}, start.Add(3*time.Minute))
+	cd.confirmed(&sn{AddressHash: "ADDR", Bundle: "B2"})
+	if c := testutil.ToFloat64(e.iotaZmqConflicts); c != 1 {
+		t.Errorf("Expected 1 conflict, got %v", c)
+	}
+	if c := testutil.ToFloat64(e.iotaZmqConflictsConfirmed); c != 1 {
+		t.Errorf("Expected 1 confirmed conflict, got %v", c)
+	}
+
+	rw := httptest.NewRecorder()
+	cd.handleConflicts(rw, httptest.NewRequest("GET", "/api/v1/conflicts", nil))
+	var conflicts []conflict
+	if err := json.NewDecoder(rw.Body).Decode(&conflicts); err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || len(conflicts[0].Bundles) != 3 || conflicts[0].Confirmed != "B2" {
+		t.Errorf("Expected a conflict of 3 bundles with B2 confirmed, got %+v", conflicts)
+	}
+
+	// Spends are forgotten after the window
+	cd.seen(&transaction{Address: "OTHER", Bundle: "B4", Value: -1}, start.Add(2*time.Hour))
+	if _, ok := cd.spends["ADDR"]; ok {
+		t.Errorf("Expected the spends of ADDR to be forgotten")
+	}
+}
+
+func TestCollectConflictsGolden(t *testing.T) {
+
+	e := newExporter("")
+	cd := newConflictDetector(e, 10, time.Hour)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// ADDR is spent in two bundles of which one confirms, OTHER in two
+	// bundles that are still pending
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B1", Value: -100}, start)
+	cd.seen(&transaction{Address: "ADDR", Bundle: "B2", Value: -100}, start)
+	cd.confirmed(&sn{AddressHash: "ADDR", Bundle: "B2"})
+	cd.seen(&transaction{Address: "OTHER", Bundle: "B3", Value: -5}, start)
+	cd.seen(&transaction{Address: "OTHER", Bundle: "B4", Value: -5}, start)
+
+	checkGolden(t, "conflicts", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectConflicts(e, ch)
+	})))
+}
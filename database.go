@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func metricsDatabase(e *exporter) {
+
+	e.iotaDBSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "db",
+			//Name: "size_bytes",
+			Name: "iota_db_size_bytes",
+			Help: "Size of the transaction database on disk.",
+		},
+		[]string{"type"},
+	)
+
+	e.iotaDBKeys = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "db",
+			//Name: "keys",
+			Name: "iota_db_keys",
+			Help: "Estimated number of transactions in the database, updated on every GC run.",
+		})
+
+	e.iotaDBGCRuns = prometheus.NewDesc(
+		"iota_db_gc_runs_total",
+		"Database GC runs by result (ok, nothing or error).",
+		[]string{"result"}, nil,
+	)
+
+	e.iotaDBGCDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "db",
+			//Name: "gc_duration_seconds",
+			Name: "iota_db_gc_duration_seconds",
+			Help: "Duration of the last database GC run.",
+		})
+
+	e.iotaDBRetention = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "db",
+			//Name: "retention_seconds",
+			Name: "iota_db_retention_seconds",
+			Help: "Current retention of seen and confirmed transactions, shortened when the database exceeds its size budget.",
+		},
+		[]string{"state"},
+	)
+}
+
+func describeDatabase(e *exporter, ch chan<- *prometheus.Desc) {
+	e.iotaDBSize.Describe(ch)
+	ch <- e.iotaDBKeys.Desc()
+	ch <- e.iotaDBGCRuns
+	ch <- e.iotaDBGCDuration.Desc()
+	e.iotaDBRetention.Describe(ch)
+}
+
+func collectDatabase(e *exporter, ch chan<- prometheus.Metric) {
+	e.iotaDBSize.Collect(ch)
+	ch <- e.iotaDBKeys
+	e.dbGCRunsLock.Lock()
+	gcRuns := make(map[string]float64, len(e.dbGCRuns))
+	for result, runs := range e.dbGCRuns {
+		gcRuns[result] = runs
+	}
+	e.dbGCRunsLock.Unlock()
+	for result, runs := range gcRuns {
+		ch <- prometheus.MustNewConstMetric(e.iotaDBGCRuns, prometheus.CounterValue, runs, result)
+	}
+	ch <- e.iotaDBGCDuration
+	e.iotaDBRetention.Collect(ch)
+}
+
+func scrapeDatabase(e *exporter) {
+	if e.zmq == nil {
+		return
+	}
+
+	stats := e.zmq.store.Stats()
+	e.iotaDBSize.WithLabelValues("lsm").Set(float64(stats.lsmSize))
+	e.iotaDBSize.WithLabelValues("vlog").Set(float64(stats.vlogSize))
+	e.iotaDBKeys.Set(float64(stats.keys))
+	e.iotaDBGCDuration.Set(stats.gcDuration)
+	e.iotaDBRetention.WithLabelValues("seen").Set(stats.retention.seen.Seconds())
+	e.iotaDBRetention.WithLabelValues("confirmed").Set(stats.retention.confirmed.Seconds())
+
+	// The store returns a copy of the map, which is only shared with
+	// collectDatabase from here on.
+	e.dbGCRunsLock.Lock()
+	e.dbGCRuns = stats.gcRuns
+	e.dbGCRunsLock.Unlock()
+}
@@ -0,0 +1,62 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+	"time"
+)
+
+// statsStore is a memory store with fixed statistics.
+type statsStore struct {
+	*memoryStore
+	stats storeStats
+}
+
+func (s *statsStore) Stats() storeStats {
+	return s.stats
+}
+
+func TestCollectDatabaseGolden(t *testing.T) {
+
+	e := newExporter("")
+	e.zmq = &zmqPipeline{store: &statsStore{
+		memoryStore: newMemoryStore(10, testRetention),
+		stats: storeStats{
+			lsmSize:    1 << 20,
+			vlogSize:   64 << 20,
+			keys:       12345,
+			gcRuns:     map[string]float64{"ok": 3, "nothing": 7, "error": 1},
+			gcDuration: 1.5,
+			retention:  storeRetention{seen: 15 * 24 * time.Hour, confirmed: 12 * time.Hour},
+		},
+	}}
+	scrapeDatabase(e)
+
+	checkGolden(t, "database", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectDatabase(e, ch)
+	})))
+}
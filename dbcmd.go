@@ -0,0 +1,347 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// importBatchSize is the number of records written at once by db import.
+const importBatchSize = 1000
+
+// exportFields are the columns of a csv export.
+var exportFields = []string{"hash", "timestamp", "firstSeen", "confirmed", "address", "value", "milestoneIn", "category"}
+
+// exportRecord is a txRecord as written by db export, with decoded times.
+type exportRecord struct {
+	Hash        string `json:"hash"`
+	Timestamp   int64  `json:"timestamp"`
+	FirstSeen   string `json:"firstSeen"`
+	Confirmed   string `json:"confirmed,omitempty"`
+	Address     string `json:"address"`
+	Value       int64  `json:"value"`
+	MilestoneIn int64  `json:"milestoneIn,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+// formatRecordTime converts a txRecord time to RFC 3339, or "" if unset.
+func formatRecordTime(t int64) string {
+	if v := recordTimeValue(t); v != nil {
+		return v.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// parseRecordTime converts an RFC 3339 time back to a txRecord time.
+func parseRecordTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return recordTime(t), nil
+}
+
+func newExportRecord(hash string, rec *txRecord) exportRecord {
+	return exportRecord{
+		Hash:        hash,
+		Timestamp:   rec.Timestamp,
+		FirstSeen:   formatRecordTime(rec.TxIn),
+		Confirmed:   formatRecordTime(rec.TxConfirmed),
+		Address:     rec.TxAddress,
+		Value:       rec.TxValue,
+		MilestoneIn: rec.MilestoneIn,
+		Category:    rec.Category,
+	}
+}
+
+func (r *exportRecord) entry() (storeEntry, error) {
+	in, err := parseRecordTime(r.FirstSeen)
+	if err != nil {
+		return storeEntry{}, err
+	}
+	confirmed, err := parseRecordTime(r.Confirmed)
+	if err != nil {
+		return storeEntry{}, err
+	}
+	return storeEntry{hash: r.Hash, rec: txRecord{
+		Timestamp:   r.Timestamp,
+		TxIn:        in,
+		TxConfirmed: confirmed,
+		TxAddress:   r.Address,
+		TxValue:     r.Value,
+		MilestoneIn: r.MilestoneIn,
+		Category:    r.Category,
+	}}, nil
+}
+
+// exportRecords writes all records of a store as JSON lines or csv and
+// returns the number of records written.
+func exportRecords(store txStore, w io.Writer, format string) (int, error) {
+	var n int
+	var werr error
+	var write func(r exportRecord) error
+	flush := func() error { return nil }
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		write = func(r exportRecord) error { return enc.Encode(&r) }
+	case "csv":
+		cw := csv.NewWriter(w)
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+		if err := cw.Write(exportFields); err != nil {
+			return 0, err
+		}
+		write = func(r exportRecord) error {
+			return cw.Write([]string{
+				r.Hash, strconv.FormatInt(r.Timestamp, 10), r.FirstSeen, r.Confirmed, r.Address,
+				strconv.FormatInt(r.Value, 10), strconv.FormatInt(r.MilestoneIn, 10), r.Category,
+			})
+		}
+	default:
+		return 0, fmt.Errorf("unknown format %q", format)
+	}
+
+	err := store.ForEach(func(hash string, rec *txRecord) bool {
+		if werr = write(newExportRecord(hash, rec)); werr != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if werr != nil {
+		return n, werr
+	} else if err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// importRecords reads records written by exportRecords into a store and
+// returns the number of records imported and skipped. Records older than
+// the retention at now are skipped, as the database maintenance would
+// delete them right away. Imported records expire like newly seen
+// transactions.
+func importRecords(store txStore, r io.Reader, format string, retention storeRetention, now time.Time) (n int, skipped int, err error) {
+	var batch []storeEntry
+	add := func(rec exportRecord) error {
+		entry, err := rec.entry()
+		if err != nil {
+			return fmt.Errorf("record %d: %v", n+skipped+1, err)
+		}
+		if retention.expired(&entry.rec, recordTime(now)) {
+			skipped++
+			return nil
+		}
+		batch = append(batch, entry)
+		n++
+		if len(batch) >= importBatchSize {
+			err = store.PutBatch(batch)
+			batch = batch[:0]
+		}
+		return err
+	}
+
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bufio.NewReader(r))
+		for {
+			rec := exportRecord{}
+			err := dec.Decode(&rec)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return n, skipped, fmt.Errorf("record %d: %v", n+skipped+1, err)
+			}
+			if err := add(rec); err != nil {
+				return n, skipped, err
+			}
+		}
+	case "csv":
+		cr := csv.NewReader(bufio.NewReader(r))
+		cr.FieldsPerRecord = len(exportFields)
+		if _, err := cr.Read(); err != nil {
+			return 0, 0, err
+		}
+		for {
+			row, err := cr.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return n, skipped, err
+			}
+			rec := exportRecord{Hash: row[0], Timestamp: stoi(row[1]), FirstSeen: row[2], Confirmed: row[3],
+				Address: row[4], Value: stoi(row[5]), MilestoneIn: stoi(row[6]), Category: row[7]}
+			if err := add(rec); err != nil {
+				return n, skipped, err
+			}
+		}
+	default:
+		return 0, 0, fmt.Errorf("unknown format %q", format)
+	}
+
+	if len(batch) > 0 {
+		return n, skipped, store.PutBatch(batch)
+	}
+	return n, skipped, nil
+}
+
+// dbSummary counts the records of a store for db stats.
+type dbSummary struct {
+	records   int64
+	confirmed int64
+	value     int64
+	pending   int64
+	oldest    int64
+	newest    int64
+}
+
+func summarizeRecords(store txStore) (dbSummary, error) {
+	s := dbSummary{}
+	err := store.ForEach(func(hash string, rec *txRecord) bool {
+		s.records++
+		if rec.TxConfirmed != 0 {
+			s.confirmed++
+		}
+		if rec.TxValue != 0 {
+			s.value++
+			if rec.TxConfirmed == 0 {
+				s.pending++
+			}
+		}
+		if s.oldest == 0 || rec.TxIn < s.oldest {
+			s.oldest = rec.TxIn
+		}
+		if rec.TxIn > s.newest {
+			s.newest = rec.TxIn
+		}
+		return true
+	})
+	return s, err
+}
+
+// openFile opens a file for db import, or stdin for -.
+func openFile(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// createFile creates a file for db export, or returns stdout for -.
+func createFile(name string) (io.WriteCloser, error) {
+	if name == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// runDBCommand runs one of the db subcommands on the badger database.
+func runDBCommand(command string) error {
+	if *databaseBackend != "badger" {
+		return fmt.Errorf("the db commands need the badger backend")
+	}
+
+	retention := storeRetention{seen: *databaseSeenTTL, confirmed: *databaseConfirmedTTL}
+	store, err := openBadgerStore(*databasePath, retention)
+	if err != nil {
+		return fmt.Errorf("could not open %s, is the exporter still running? %v", *databasePath, err)
+	}
+	defer store.Close()
+
+	switch command {
+	case dbStatsCommand.FullCommand():
+		s, err := summarizeRecords(store)
+		if err != nil {
+			return err
+		}
+		lsm, vlog := store.diskSize()
+		fmt.Printf("Records:             %d\n", s.records)
+		fmt.Printf("Confirmed:           %d\n", s.confirmed)
+		fmt.Printf("Value transactions:  %d (%d pending)\n", s.value, s.pending)
+		fmt.Printf("First seen:          %s - %s\n", formatRecordTime(s.oldest), formatRecordTime(s.newest))
+		fmt.Printf("LSM size:            %d bytes\n", lsm)
+		fmt.Printf("Value log size:      %d bytes\n", vlog)
+
+	case dbExportCommand.FullCommand():
+		w, err := createFile(*dbExportOutput)
+		if err != nil {
+			return err
+		}
+		n, err := exportRecords(store, w, *dbExportFormat)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d records.\n", n)
+
+	case dbImportCommand.FullCommand():
+		r, err := openFile(*dbImportInput)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		n, skipped, err := importRecords(store, r, *dbImportFormat, retention, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Imported %d records.\n", n)
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Skipped %d records older than the retention (--db.seen-ttl and --db.confirmed-ttl).\n", skipped)
+		}
+
+	case dbCompactCommand.FullCommand():
+		lsmBefore, vlogBefore := store.diskSize()
+		if _, err := store.expire(retention, true); err != nil {
+			return err
+		}
+		if result := store.gc(*databaseGCDiscardRatio); result == "error" {
+			return fmt.Errorf("value log GC failed")
+		}
+		lsmAfter, vlogAfter := store.diskSize()
+		fmt.Printf("LSM size %d bytes before, %d bytes after compaction.\n", lsmBefore, lsmAfter)
+		fmt.Printf("Value log size %d bytes before, %d bytes after compaction.\n", vlogBefore, vlogAfter)
+		fmt.Printf("Total size %d bytes before, %d bytes after compaction.\n", lsmBefore+vlogBefore, lsmAfter+vlogAfter)
+
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImportRecords(t *testing.T) {
+
+	records := map[string]txRecord{
+		"CONFIRMED": {Timestamp: 1520164800, TxIn: 20180304120000, TxConfirmed: 20180304121000, TxAddress: "ADDR", TxValue: -42, MilestoneIn: 400000, Category: "exchange"},
+		"PENDING":   {Timestamp: 1520164900, TxIn: 20180304120140, TxAddress: "OTHER"},
+	}
+
+	now := time.Date(2018, 3, 4, 13, 0, 0, 0, time.UTC)
+	for _, format := range []string{"json", "csv"} {
+		src := newMemoryStore(10, testRetention)
+		for hash, rec := range records {
+			rec := rec
+			src.Put(hash, &rec) // nolint: errcheck
+		}
+
+		var buf bytes.Buffer
+		if n, err := exportRecords(src, &buf, format); err != nil || n != 2 {
+			t.Fatalf("Test %v: Expected 2 exported records, got %v %v", format, n, err)
+		}
+		if !strings.Contains(buf.String(), "2018-03-04T12:10:00Z") {
+			t.Errorf("Test %v: Expected decoded times in the export, got %s", format, buf.String())
+		}
+
+		archive := buf.String()
+		dst := newMemoryStore(10, testRetention)
+		if n, skipped, err := importRecords(dst, &buf, format, testRetention, now); err != nil || n != 2 || skipped != 0 {
+			t.Fatalf("Test %v: Expected 2 imported records, got %v %v %v", format, n, skipped, err)
+		}
+		for hash, rec := range records {
+			if got, err := dst.Get(hash); err != nil || *got != rec {
+				t.Errorf("Test %v: Expected %v for %v, got %v %v", format, rec, hash, got, err)
+			}
+		}
+
+		s, err := summarizeRecords(dst)
+		if err != nil || s.records != 2 || s.confirmed != 1 || s.value != 1 || s.pending != 0 || s.oldest != 20180304120000 {
+			t.Errorf("Test %v: Unexpected summary %+v %v", format, s, err)
+		}
+
+		// Two days later the confirmed record is beyond the retention
+		dst = newMemoryStore(10, testRetention)
+		n, skipped, err := importRecords(dst, strings.NewReader(archive), format, testRetention, now.Add(48*time.Hour))
+		if err != nil || n != 1 || skipped != 1 {
+			t.Errorf("Test %v: Expected 1 imported and 1 skipped record, got %v %v %v", format, n, skipped, err)
+		}
+		if _, err := dst.Get("CONFIRMED"); err != errTxNotFound {
+			t.Errorf("Test %v: Expected the expired record not to be imported, got %v", format, err)
+		}
+	}
+
+	if _, _, err := importRecords(newMemoryStore(10, testRetention), strings.NewReader(`{"hash":"X","firstSeen":"yesterday"}`), "json", testRetention, now); err == nil {
+		t.Errorf("Expected an error importing an invalid time")
+	}
+}
+
+// failingWriter fails every write, like a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestExportRecordsWriteError(t *testing.T) {
+
+	src := newMemoryStore(10, testRetention)
+	src.Put("PENDING", &txRecord{TxIn: 20180304120140}) // nolint: errcheck
+
+	for _, format := range []string{"json", "csv"} {
+		if _, err := exportRecords(src, failingWriter{}, format); err == nil {
+			t.Errorf("Test %v: Expected the write error to be returned", format)
+		}
+	}
+}
@@ -32,7 +32,12 @@ import (
 	"github.com/prometheus/common/log"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"sync"
+	"syscall"
+	"time"
 )
 
 // Version is set during build to the git Describe version
@@ -40,6 +45,32 @@ import (
 var Version = "0.4.3"
 
 var (
+	serveCommand        = kingpin.Command("serve", "Run the exporter.").Default()
+	dbCommand           = kingpin.Command("db", "Inspect and maintain the database while the exporter is stopped.")
+	dbStatsCommand      = dbCommand.Command("stats", "Show the number of records and the size of the database.")
+	dbExportCommand     = dbCommand.Command("export", "Write all transaction records with decoded times.")
+	dbExportFormat      = dbExportCommand.Flag("format", "Output format: json (one record per line) or csv.").Default("json").Enum("json", "csv")
+	dbExportOutput      = dbExportCommand.Flag("output", "File to write to, - for stdout.").Default("-").String()
+	dbImportCommand     = dbCommand.Command("import", "Read transaction records written by db export.")
+	dbImportFormat      = dbImportCommand.Flag("format", "Input format: json or csv.").Default("json").Enum("json", "csv")
+	dbImportInput       = dbImportCommand.Arg("file", "File to read, - for stdin.").Default("-").String()
+	dbCompactCommand    = dbCommand.Command("compact", "Remove expired records and reclaim disk space.")
+	zmqCommand          = kingpin.Command("zmq", "Record the ZMQ stream of IRI.")
+	zmqRecordCommand    = zmqCommand.Command("record", "Write the messages of --web.zmq-path to a compressed file for --zmq.replay-file.")
+	zmqRecordOutput     = zmqRecordCommand.Flag("output", "File to write the recording to.").Default("zmq-recording.gz").String()
+	zmqRecordFor        = zmqRecordCommand.Flag("duration", "How long to record, 0 records until interrupted.").Default("0").Duration()
+	loadgenCommand      = kingpin.Command("loadgen", "Publish synthetic ZMQ messages to test how many tx/s the exporter sustains.")
+	loadgenAddress      = loadgenCommand.Flag("bind", "Address to publish the messages on.").Default("tcp://127.0.0.1:5556").String()
+	loadgenRate         = loadgenCommand.Flag("rate", "Transactions published per second.").Default("100").Int()
+	loadgenDuration     = loadgenCommand.Flag("duration", "How long to publish, 0 publishes until interrupted.").Default("0").Duration()
+	loadgenValueRatio   = loadgenCommand.Flag("value-ratio", "Fraction of the bundles that move value.").Default("0.05").Float64()
+	loadgenConfirmRatio = loadgenCommand.Flag("confirm-ratio", "Fraction of the bundles that get confirmed.").Default("0.5").Float64()
+	loadgenDoubleSpend  = loadgenCommand.Flag("double-spend-ratio", "Fraction of the value bundles that spend an input of an earlier bundle again.").Default("0.01").Float64()
+	loadgenSeed         = loadgenCommand.Flag("seed", "Seed of the generated messages, so a run can be repeated. 0 picks a seed from the clock.").Default("0").Int64()
+	loadgenConfirmDelay = loadgenCommand.Flag("confirm-delay", "Time from publishing a bundle until its confirmation.").Default("2m").Duration()
+	loadgenBenchmark    = loadgenCommand.Flag("benchmark", "Run the ZMQ pipeline of the exporter against the published messages and report its lag, drops and memory.").Bool()
+	loadgenReport       = loadgenCommand.Flag("report-interval", "Interval between benchmark reports.").Default("10s").Duration()
+
 	listenAddress    = kingpin.Flag("web.listen-address", "Address to listen on for web interface and telemetry.").Default(":9311").String()
 	metricPath       = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
 	targetAddress    = kingpin.Flag("web.iri-path", "URI of the IOTA IRI Node to scrape.").Default("http://localhost:14265").String()
@@ -47,6 +78,63 @@ var (
 	enableZmq        = kingpin.Flag("zmq", "Enable ZMQ based metrics (database required).").Default("true").Bool()
 	targetZmqAddress = kingpin.Flag("web.zmq-path", "URI of the IOTA IRI ZMQ Node to scrape.").Default("tcp://localhost:5556").String()
 	databasePath     = kingpin.Flag("db.database-path", "Path for the database.").Default("./iotabadgerdb").String()
+	databaseBackend  = kingpin.Flag("db.backend", "Database backend used to track transactions: badger or memory.").Default("badger").Enum("badger", "memory")
+	memoryMaxRecords = kingpin.Flag("db.memory-max-records", "Maximum number of transactions kept by the memory backend.").Default("100000").Int()
+
+	databaseSeenTTL        = kingpin.Flag("db.seen-ttl", "How long transactions that are not confirmed are kept in the database.").Default("360h").Duration()
+	databaseConfirmedTTL   = kingpin.Flag("db.confirmed-ttl", "How long confirmed transactions are kept in the database.").Default("24h").Duration()
+	databaseGCInterval     = kingpin.Flag("db.gc-interval", "Interval between database GC runs.").Default("15m").Duration()
+	databaseGCDiscardRatio = kingpin.Flag("db.gc-discard-ratio", "Fraction of a value log file that must be stale before GC rewrites it.").Default("0.5").Float64()
+	databaseMaxSize        = kingpin.Flag("db.max-size", "Disk budget of the database (e.g. 2GB), retention is tightened when exceeded. 0 disables the guard.").Default("0").Bytes()
+
+	zmqConfirmBuckets          = kingpin.Flag("zmq.confirm-buckets", "Confirmation time histogram buckets in seconds, as a comma separated list, exp:start,factor,count or lin:start,width,count. Native histograms are not supported.").Default("300,600,1200,2400,3600,7200,21600,43200").String()
+	zmqConfirmMilestoneBuckets = kingpin.Flag("zmq.confirm-milestone-buckets", "Confirmation histogram buckets in milestones, same format as --zmq.confirm-buckets.").Default("1,2,3,4,5,10,20,50").String()
+	zmqConfirmWindow           = kingpin.Flag("zmq.confirm-window", "Sliding window for the confirmation time quantiles.").Default("10m").Duration()
+	zmqQueueSize               = kingpin.Flag("zmq.queue-size", "Number of ZMQ messages that can wait for a database worker.").Default("10000").Int()
+	zmqWorkers                 = kingpin.Flag("zmq.workers", "Number of database workers handling ZMQ messages.").Default("2").Int()
+	zmqOverload                = kingpin.Flag("zmq.overload", "What to do with ZMQ messages when the queue is full: drop-newest, drop-oldest or block.").Default("drop-newest").Enum("drop-newest", "drop-oldest", "block")
+	databaseSkipZeroValue      = kingpin.Flag("db.skip-zero-value", "Do not store zero value transactions in the database, only remember them in a bloom filter.").Default("false").Bool()
+	lookupBloomCapacity        = kingpin.Flag("lookup.bloom-capacity", "Number of zero value transactions a bloom filter of the tx lookup holds before it is rotated.").Default("1000000").Int()
+	lookupBloomWindow          = kingpin.Flag("lookup.bloom-window", "Time after which a bloom filter of the tx lookup is rotated.").Default("1h").Duration()
+	databaseBatchSize          = kingpin.Flag("db.batch-size", "Maximum number of transactions written to the database at once.").Default("100").Int()
+	databaseBatchInterval      = kingpin.Flag("db.batch-interval", "Maximum time a transaction waits before its batch is written.").Default("1s").Duration()
+	zmqCheckpointInterval      = kingpin.Flag("zmq.checkpoint-interval", "Interval between saving the ZMQ accumulators to the database, 0 disables the checkpoint.").Default("1m").Duration()
+	zmqBundleMax               = kingpin.Flag("zmq.bundle-max", "Maximum number of bundles tracked until they are confirmed.").Default("20000").Int()
+	zmqBundleTimeout           = kingpin.Flag("zmq.bundle-timeout", "How long a bundle is tracked before giving up on its confirmation.").Default("6h").Duration()
+	zmqTimestampFuture         = kingpin.Flag("zmq.timestamp-future", "Transactions with a timestamp further ahead of their arrival are counted as anomalies.").Default("5m").Duration()
+	zmqTimestampPast           = kingpin.Flag("zmq.timestamp-past", "Transactions with a timestamp further behind their arrival are counted as anomalies.").Default("2h").Duration()
+	zmqConflictWindow          = kingpin.Flag("zmq.conflict-window", "How long the inputs of an address are kept to detect conflicting spends.").Default("24h").Duration()
+	zmqConflictMax             = kingpin.Flag("zmq.conflict-max-addresses", "Maximum number of input addresses tracked to detect conflicting spends.").Default("50000").Int()
+	pendingScanInterval        = kingpin.Flag("pending.scan-interval", "Interval between database scans for value transactions that are not confirmed. 0 disables the scan.").Default("5m").Duration()
+	pendingListSize            = kingpin.Flag("pending.list-size", "Number of the oldest pending value transactions listed on /pending.").Default("100").Int()
+	zmqReplayFile              = kingpin.Flag("zmq.replay-file", "Replay a recording made with zmq record instead of connecting to --web.zmq-path.").Default("").String()
+	zmqReplaySpeed             = kingpin.Flag("zmq.replay-speed", "Speed multiplier of the replay, 0 replays as fast as possible.").Default("1").Float64()
+	zmqCategoriesFile          = kingpin.Flag("zmq.categories-file", "JSON file with rules mapping tags and addresses to transaction categories.").Default("").String()
+	zmqCategoriesMax           = kingpin.Flag("zmq.categories-max", "Maximum number of categories named after tags ($tag rules) exported, further tags are counted as other.").Default("50").Int()
+
+	milestoneStallThreshold = kingpin.Flag("milestone.stall-threshold", "Milestones are reported as stalled when none arrived for this long.").Default("10m").Duration()
+
+	tangleWindow = kingpin.Flag("tangle.window", "Transactions not approved within this window are counted as orphans.").Default("10m").Duration()
+	tangleMaxTxs = kingpin.Flag("tangle.max-txs", "Maximum number of transactions kept in the approval graph. 0 disables the graph.").Default("100000").Int()
+
+	spamInterval    = kingpin.Flag("spam.interval", "Interval over which zero value transaction rates are compared with their baseline. 0 disables the detector.").Default("10s").Duration()
+	spamAlpha       = kingpin.Flag("spam.alpha", "Weight of the last interval in the moving average baselines.").Default("0.05").Float64()
+	spamSensitivity = kingpin.Flag("spam.sensitivity", "A tag or address is flagged when its rate exceeds this multiple of its baseline.").Default("5").Float64()
+	spamMinRate     = kingpin.Flag("spam.min-rate", "Minimum zero value transactions per second for a tag or address to be flagged.").Default("5").Float64()
+	spamMaxSources  = kingpin.Flag("spam.max-sources", "Maximum number of tags and addresses with a baseline.").Default("10000").Int()
+
+	topN       = kingpin.Flag("top.n", "Number of most frequent tags, addresses and senders exported per window. 0 disables the tracker.").Default("10").Int()
+	topWindows = kingpin.Flag("top.windows", "Comma separated list of windows over which the most frequent keys are counted.").Default("5m,1h").String()
+
+	watchFile             = kingpin.Flag("watch.file", "JSON file with the addresses to watch, written back when the list is changed through the API.").Default("").String()
+	watchPendingTimeout   = kingpin.Flag("watch.pending-timeout", "How long a value transaction of a watched address is pending before it is forgotten.").Default("24h").Duration()
+	watchBalanceInterval  = kingpin.Flag("watch.balance-interval", "Interval between getBalances checks of the watched addresses. 0 disables the check.").Default("0").Duration()
+	finalityMax           = kingpin.Flag("finality.max", "Maximum number of transactions and bundles tracked through the finality API.").Default("10000").Int()
+	finalityTimeout       = kingpin.Flag("finality.timeout", "How long a registered transaction is tracked before it is reported as expired.").Default("24h").Duration()
+	finalityRetention     = kingpin.Flag("finality.retention", "How long the status of a confirmed or expired transaction stays available.").Default("1h").Duration()
+	finalityCheckInterval = kingpin.Flag("finality.check-interval", "Interval between getInclusionStates checks of pending transactions. 0 disables the check.").Default("1m").Duration()
+	finalityWebhook       = kingpin.Flag("finality.webhook", "URL that is sent a POST request when a registered transaction confirms.").Default("").String()
+	adminToken            = kingpin.Flag("web.admin-token", "Bearer token required by API requests that change the exporter state. The admin API is disabled without it.").Default("").String()
 )
 
 const (
@@ -54,7 +142,28 @@ const (
 )
 
 type exporter struct {
-	iriAddress string
+	iriAddress      string
+	zmq             *zmqPipeline
+	bundles         *bundleTracker
+	watch           *watchList
+	finality        *finalityTracker
+	categories      *categorizer
+	top             *topTracker
+	milestones      *milestoneTracker
+	milestoneGroups *milestoneGroups
+	tangle          *approvalGraph
+	conflicts       *conflictDetector
+	spam            *spamDetector
+	pending         *pendingScanner
+
+	// zeroValue holds the zero value transactions seen by zeroMQ, which are
+	// not stored in the database with --db.skip-zero-value.
+	zeroValue *seenFilter
+
+	// dbGCRuns holds the GC runs by result as of the last scrape, guarded
+	// by dbGCRunsLock as scrapes may run concurrently.
+	dbGCRuns     map[string]float64
+	dbGCRunsLock sync.Mutex
 
 	iotaNodeInfoTotalScrapes             prometheus.Counter
 	iotaNodeInfoDuration                 prometheus.Gauge
@@ -67,6 +176,13 @@ type exporter struct {
 	iotaNodeInfoTotalNeighbors           prometheus.Gauge
 	iotaNodeInfoTotalTips                prometheus.Gauge
 	iotaNodeInfoTotalTransactionsQueued  prometheus.Gauge
+	iotaMilestoneInterval                prometheus.Histogram
+	iotaMilestoneSinceLast               prometheus.Gauge
+	iotaMilestoneStalled                 prometheus.Gauge
+	iotaMilestoneConfirmedTxs            prometheus.Histogram
+	iotaMilestoneConfirmedValue          prometheus.Histogram
+	iotaMilestoneSeenRatio               prometheus.Histogram
+	iotaMilestoneLastSeenRatio           prometheus.Gauge
 	iotaNeighborsInfoTotalNeighbors      prometheus.Gauge
 	iotaNeighborsInfoActiveNeighbors     prometheus.Gauge
 	iotaNeighborsNewTransactions         *prometheus.GaugeVec
@@ -83,7 +199,62 @@ type exporter struct {
 	iotaZmqToBroadcast                   prometheus.Gauge
 	iotaZmqToReply                       prometheus.Gauge
 	iotaZmqTotalTransactions             prometheus.Gauge
-	iotaZmqConfirmationHisto             *prometheus.HistogramVec
+	iotaZmqConfirmationHisto             *persistentHistogramVec
+	iotaZmqConfirmationSummary           *prometheus.SummaryVec
+	iotaZmqConfirmationMilestonesHisto   *persistentHistogramVec
+	iotaZmqSeenTxTotal                   *prometheus.Desc
+	iotaZmqConfirmedTxTotal              *prometheus.Desc
+	iotaZmqTPS                           *prometheus.GaugeVec
+	iotaZmqCTPS                          *prometheus.GaugeVec
+	iotaZmqConfirmationRate              *prometheus.GaugeVec
+	iotaZmqBundleSize                    prometheus.Histogram
+	iotaZmqBundles                       *prometheus.CounterVec
+	iotaZmqBundleConfirmTime             *prometheus.HistogramVec
+	iotaZmqBundlesTracked                prometheus.Gauge
+	iotaZmqReattachments                 prometheus.Counter
+	iotaZmqPromotions                    prometheus.Counter
+	iotaZmqBundleReattachments           prometheus.Histogram
+	iotaWatchTxs                         *prometheus.Desc
+	iotaWatchSeenValue                   *prometheus.Desc
+	iotaWatchPendingValue                *prometheus.Desc
+	iotaWatchConfirmedValue              *prometheus.Desc
+	iotaWatchBalance                     *prometheus.Desc
+	iotaZmqCategorySeen                  *prometheus.CounterVec
+	iotaZmqCategoryConfirmed             *prometheus.CounterVec
+	iotaZmqCategoryConfirmTime           *prometheus.HistogramVec
+	iotaZmqArrivalDelay                  prometheus.Histogram
+	iotaZmqTimestampAnomalies            *prometheus.CounterVec
+	iotaZmqValueMoved                    *prometheus.Desc
+	iotaZmqValueMovedMi                  *prometheus.Desc
+	iotaZmqValueConfirmed                *prometheus.Desc
+	iotaZmqValueConfirmedRatio           prometheus.Gauge
+	iotaZmqValueSize                     prometheus.Histogram
+	iotaTangleTips                       prometheus.Gauge
+	iotaTangleApprovalAge                prometheus.Histogram
+	iotaTangleReferences                 *prometheus.CounterVec
+	iotaTangleEvaluated                  prometheus.Counter
+	iotaTangleOrphans                    prometheus.Counter
+	iotaTangleOrphanRate                 prometheus.Gauge
+	iotaZmqConflicts                     prometheus.Counter
+	iotaZmqConflictsConfirmed            prometheus.Counter
+	iotaZmqSpamScore                     prometheus.Gauge
+	iotaZmqSpamEvents                    *prometheus.CounterVec
+	iotaZmqSpamActive                    *prometheus.Desc
+	iotaZmqPendingTxs                    *prometheus.GaugeVec
+	iotaZmqPendingValue                  prometheus.Gauge
+	iotaZmqPendingScanDuration           prometheus.Gauge
+	iotaZmqTop                           *prometheus.Desc
+	iotaFinalityConfirmTime              *prometheus.HistogramVec
+	iotaFinalityTracked                  *prometheus.GaugeVec
+	iotaZmqQueueDepth                    prometheus.Gauge
+	iotaZmqDroppedMessages               *prometheus.CounterVec
+	iotaZmqQueueLag                      prometheus.Gauge
+	iotaZmqDBWriteDuration               *prometheus.HistogramVec
+	iotaDBSize                           *prometheus.GaugeVec
+	iotaDBKeys                           prometheus.Gauge
+	iotaDBGCRuns                         *prometheus.Desc
+	iotaDBGCDuration                     prometheus.Gauge
+	iotaDBRetention                      *prometheus.GaugeVec
 	iotaMarketTradePrice                 *prometheus.GaugeVec
 	iotaMarketTradeVolume                *prometheus.GaugeVec
 	iotaMarketHighPrice                  *prometheus.GaugeVec
@@ -106,9 +277,26 @@ func newExporter(iriAddress string) *exporter {
 
 	metricsNodeinfo(e)
 	metricsNeighbors(e)
+	metricsMilestones(e)
 	metricsZmq(e)
+	metricsZmqRates(e)
+	metricsValue(e)
+	metricsPending(e)
+	metricsLatency(e)
+	metricsBundles(e)
+	metricsTangle(e)
+	metricsSpam(e)
+	metricsConflicts(e)
+	metricsCategories(e)
+	metricsTop(e)
+	metricsWatch(e)
+	metricsFinality(e)
+	metricsDatabase(e)
 	metricsBitfinex(e)
 
+	e.milestones = newMilestoneTracker(e, time.Now())
+	e.milestoneGroups = newMilestoneGroups(e)
+
 	return e
 }
 
@@ -118,7 +306,21 @@ func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
 
 	describeNodeinfo(e, ch)
 	describeNeighbors(e, ch)
+	describeMilestones(e, ch)
 	describeZmq(e, ch)
+	describeZmqRates(e, ch)
+	describeValue(e, ch)
+	describePending(e, ch)
+	describeLatency(e, ch)
+	describeBundles(e, ch)
+	describeTangle(e, ch)
+	describeSpam(e, ch)
+	describeConflicts(e, ch)
+	describeCategories(e, ch)
+	describeTop(e, ch)
+	describeWatch(e, ch)
+	describeFinality(e, ch)
+	describeDatabase(e, ch)
 	describeBitfinex(e, ch)
 }
 
@@ -128,7 +330,21 @@ func (e *exporter) Collect(ch chan<- prometheus.Metric) {
 
 	collectNodeinfo(e, ch)
 	collectNeighbors(e, ch)
+	collectMilestones(e, ch)
 	collectZmq(e, ch)
+	collectZmqRates(e, ch)
+	collectValue(e, ch)
+	collectPending(e, ch)
+	collectLatency(e, ch)
+	collectBundles(e, ch)
+	collectTangle(e, ch)
+	collectSpam(e, ch)
+	collectConflicts(e, ch)
+	collectCategories(e, ch)
+	collectTop(e, ch)
+	collectWatch(e, ch)
+	collectFinality(e, ch)
+	collectDatabase(e, ch)
 	collectBitfinex(e, ch)
 }
 
@@ -138,8 +354,18 @@ func (e *exporter) scrape(ch chan<- prometheus.Metric) {
 
 	scrapeNodeinfo(e, api)
 	scrapeNeighbors(e, api)
+	scrapeMilestones(e)
 	if *enableZmq == true {
 		scrapeZmq(e)
+		scrapeZmqRates(e)
+		scrapeValue(e)
+		scrapePending(e)
+		scrapeBundles(e)
+		scrapeTangle(e)
+		scrapeSpam(e)
+		scrapeWatch(e)
+		scrapeFinality(e)
+		scrapeDatabase(e)
 	}
 	if *enableBitfinex == true {
 		scrapeBitfinex(e)
@@ -149,7 +375,32 @@ func (e *exporter) scrape(ch chan<- prometheus.Metric) {
 func main() {
 	kingpin.Version(fmt.Sprintf("iota-iri_exporter %s (built with %s)\n", Version, runtime.Version()))
 	log.AddFlags(kingpin.CommandLine)
-	kingpin.Parse()
+	command := kingpin.Parse()
+
+	switch command {
+	case serveCommand.FullCommand():
+	case zmqRecordCommand.FullCommand():
+		if err := recordZmq(*targetZmqAddress, *zmqRecordOutput, *zmqRecordFor); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case loadgenCommand.FullCommand():
+		seed := *loadgenSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		log.Infof("Generating messages with --seed=%d.", seed)
+		g := newLoadGenerator(seed, *loadgenValueRatio, *loadgenConfirmRatio, *loadgenDoubleSpend, *loadgenConfirmDelay)
+		if err := runLoadgen(g, *loadgenAddress, *loadgenRate, *loadgenDuration, *loadgenBenchmark, *loadgenReport); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		if err := runDBCommand(command); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// landingPage contains the HTML served at '/'.
 	// TODO: Make this nicer and more informative.
@@ -165,10 +416,39 @@ func main() {
 	exporter := newExporter(*targetAddress)
 	prometheus.MustRegister(exporter)
 
+	if *adminToken == "" {
+		log.Info("No --web.admin-token set, the admin API is disabled.")
+	}
+
 	if *enableZmq == true {
-		initZmq(targetZmqAddress)
+		initZmq(exporter, targetZmqAddress)
+		http.HandleFunc("/api/v1/watch", exporter.watch.handleWatch)
+		http.HandleFunc("/api/v1/watch/", exporter.watch.handleWatchAddress)
+		http.HandleFunc("/api/v1/finality", exporter.finality.handleFinality)
+		http.HandleFunc("/api/v1/finality/", exporter.finality.handleFinalityHash)
+		if exporter.top != nil {
+			http.HandleFunc("/top", exporter.top.handleTop)
+		}
+		http.HandleFunc("/api/v1/conflicts", exporter.conflicts.handleConflicts)
+		if exporter.spam != nil {
+			http.HandleFunc("/api/v1/spam", exporter.spam.handleSpam)
+		}
+		initPending(exporter)
+		http.HandleFunc("/api/v1/tx/", handleTx(exporter))
 	}
 
+	// Save the ZMQ accumulators before exiting
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Info("Shutting down.")
+		if *enableZmq == true {
+			stopZmq(exporter)
+		}
+		os.Exit(0)
+	}()
+
 	http.Handle(*metricPath, promhttp.Handler())
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write(landingPage) // nolint: errcheck
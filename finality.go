@@ -0,0 +1,398 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/iotaledger/giota"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errFinalityFull = errors.New("too many tracked transactions")
+
+// finalityItem is a transaction or bundle registered by a client to be
+// followed until it is confirmed.
+type finalityItem struct {
+	Hash       string     `json:"hash"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Registered time.Time  `json:"registered"`
+	Confirmed  *time.Time `json:"confirmed,omitempty"`
+	Milestone  int64      `json:"milestone,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	Seconds    float64    `json:"seconds,omitempty"`
+
+	// txs holds the transactions seen for a bundle, used for the
+	// getInclusionStates fallback.
+	txs map[string]bool
+}
+
+// finalityRequest is the body of a registration.
+type finalityRequest struct {
+	Transactions []string `json:"transactions"`
+	Bundles      []string `json:"bundles"`
+}
+
+// finalityTracker follows registered transactions and bundles on the sn
+// stream. Items that are pending for a while are checked with
+// getInclusionStates in case the sn message was missed. Confirmed and expired
+// items are kept for retention so clients can fetch their status.
+type finalityTracker struct {
+	sync.Mutex
+	max       int
+	timeout   time.Duration
+	retention time.Duration
+	webhook   string
+	items     map[string]*finalityItem
+
+	confirmTime *prometheus.HistogramVec
+	client      *http.Client
+}
+
+func newFinalityTracker(e *exporter, max int, timeout, retention time.Duration, webhook string) *finalityTracker {
+	return &finalityTracker{
+		max:         max,
+		timeout:     timeout,
+		retention:   retention,
+		webhook:     webhook,
+		items:       make(map[string]*finalityItem),
+		confirmTime: e.iotaFinalityConfirmTime,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func metricsFinality(e *exporter) {
+
+	confirmBuckets, err := parseBuckets(*zmqConfirmBuckets)
+	if err != nil {
+		log.Fatalf("Invalid --zmq.confirm-buckets: %v", err)
+	}
+
+	e.iotaFinalityConfirmTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "finality",
+			//Name: "finality_confirm_time",
+			Name:    "iota_finality_confirm_time",
+			Help:    "Seconds from registering a transaction or bundle until it is confirmed.",
+			Buckets: confirmBuckets,
+		},
+		[]string{"kind"},
+	)
+
+	e.iotaFinalityTracked = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "finality",
+			//Name: "finality_tracked",
+			Name: "iota_finality_tracked",
+			Help: "Registered transactions and bundles by status.",
+		},
+		[]string{"status"},
+	)
+}
+
+func describeFinality(e *exporter, ch chan<- *prometheus.Desc) {
+	e.iotaFinalityConfirmTime.Describe(ch)
+	e.iotaFinalityTracked.Describe(ch)
+}
+
+func collectFinality(e *exporter, ch chan<- prometheus.Metric) {
+	e.iotaFinalityConfirmTime.Collect(ch)
+	e.iotaFinalityTracked.Collect(ch)
+}
+
+func scrapeFinality(e *exporter) {
+	if e.finality == nil {
+		return
+	}
+
+	counts := e.finality.expire(time.Now())
+	for _, status := range []string{"pending", "confirmed", "expired"} {
+		e.iotaFinalityTracked.WithLabelValues(status).Set(float64(counts[status]))
+	}
+}
+
+// register starts tracking a transaction (kind tx) or bundle.
+func (f *finalityTracker) register(hash, kind string, now time.Time) (*finalityItem, error) {
+	if !isTrytes(hash) || len(hash) != 81 {
+		return nil, errors.New("invalid hash " + hash)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if item, ok := f.items[hash]; ok {
+		return item, nil
+	}
+	if len(f.items) >= f.max {
+		return nil, errFinalityFull
+	}
+
+	item := &finalityItem{Hash: hash, Kind: kind, Status: "pending", Registered: now}
+	if kind == "bundle" {
+		item.txs = make(map[string]bool)
+	}
+	f.items[hash] = item
+	return item, nil
+}
+
+// seen remembers the transactions of registered bundles.
+func (f *finalityTracker) seen(tx *transaction) {
+	f.Lock()
+	defer f.Unlock()
+
+	if item, ok := f.items[tx.Bundle]; ok && item.Kind == "bundle" {
+		item.txs[tx.Hash] = true
+	}
+}
+
+// confirmed marks registered transactions and bundles as confirmed by a sn
+// message.
+func (f *finalityTracker) confirmed(sn *sn, now time.Time) {
+	f.Lock()
+	defer f.Unlock()
+
+	if item, ok := f.items[sn.Hash]; ok && item.Kind == "tx" {
+		f.confirm(item, now, stoi(sn.Index), "zmq")
+	}
+	if item, ok := f.items[sn.Bundle]; ok && item.Kind == "bundle" {
+		f.confirm(item, now, stoi(sn.Index), "zmq")
+	}
+}
+
+// confirm must be called with the lock held.
+func (f *finalityTracker) confirm(item *finalityItem, now time.Time, milestone int64, source string) {
+	if item.Status != "pending" {
+		return
+	}
+
+	item.Status = "confirmed"
+	item.Confirmed = &now
+	item.Milestone = milestone
+	item.Source = source
+	item.Seconds = now.Sub(item.Registered).Seconds()
+	f.confirmTime.WithLabelValues(item.Kind).Observe(item.Seconds)
+
+	if f.webhook != "" {
+		go f.notify(*item)
+	}
+}
+
+// notify posts a confirmed item to the webhook.
+func (f *finalityTracker) notify(item finalityItem) {
+	body, err := json.Marshal(item)
+	if err != nil {
+		return
+	}
+	resp, err := f.client.Post(f.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Infof("Finality webhook error %v.", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Infof("Finality webhook returned %v.", resp.Status)
+	}
+}
+
+// expire marks items pending for longer than timeout as expired, forgets
+// items that finished longer than retention ago and returns the number of
+// items by status.
+func (f *finalityTracker) expire(now time.Time) map[string]int {
+	f.Lock()
+	defer f.Unlock()
+
+	counts := make(map[string]int)
+	for hash, item := range f.items {
+		if item.Status == "pending" && now.Sub(item.Registered) > f.timeout {
+			item.Status = "expired"
+			item.Confirmed = nil
+		}
+		if item.Status != "pending" && now.Sub(item.Registered) > f.timeout+f.retention {
+			delete(f.items, hash)
+			continue
+		}
+		if item.Confirmed != nil && now.Sub(*item.Confirmed) > f.retention {
+			delete(f.items, hash)
+			continue
+		}
+		counts[item.Status]++
+	}
+	return counts
+}
+
+// pending returns the transactions to check with getInclusionStates, mapped
+// to the item they belong to.
+func (f *finalityTracker) pending() map[string]string {
+	f.Lock()
+	defer f.Unlock()
+
+	txs := make(map[string]string)
+	for hash, item := range f.items {
+		if item.Status != "pending" {
+			continue
+		}
+		if item.Kind == "tx" {
+			txs[hash] = hash
+		}
+		for tx := range item.txs {
+			txs[tx] = hash
+		}
+	}
+	return txs
+}
+
+// checkInclusion confirms pending items that IRI reports as included by the
+// latest milestone.
+func (f *finalityTracker) checkInclusion(api *giota.API, now time.Time) error {
+	txs := f.pending()
+	if len(txs) == 0 {
+		return nil
+	}
+
+	info, err := api.GetNodeInfo()
+	if err != nil {
+		return err
+	}
+
+	hashes := make([]giota.Trytes, 0, len(txs))
+	for tx := range txs {
+		hashes = append(hashes, giota.Trytes(tx))
+	}
+	resp, err := api.GetInclusionStates(hashes, []giota.Trytes{info.LatestMilestone})
+	if err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	for i, included := range resp.States {
+		if !included || i >= len(hashes) {
+			continue
+		}
+		if item, ok := f.items[txs[string(hashes[i])]]; ok {
+			f.confirm(item, now, info.LatestMilestoneIndex, "inclusion")
+		}
+	}
+	return nil
+}
+
+// checkInclusionLoop runs checkInclusion every interval.
+func (f *finalityTracker) checkInclusionLoop(iriAddress string, interval time.Duration) {
+	api := giota.NewAPI(iriAddress, nil)
+	for {
+		time.Sleep(interval)
+		if err := f.checkInclusion(api, time.Now()); err != nil {
+			log.Info(err)
+		}
+	}
+}
+
+func (f *finalityTracker) list() []finalityItem {
+	f.Lock()
+	defer f.Unlock()
+
+	list := make([]finalityItem, 0, len(f.items))
+	for _, item := range f.items {
+		list = append(list, *item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Registered.Before(list[j].Registered) })
+	return list
+}
+
+// handleFinality lists the tracked items (GET) or registers new ones (POST).
+func (f *finalityTracker) handleFinality(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(rw, http.StatusOK, f.list())
+
+	case "POST":
+		if !authorized(rw, r) {
+			return
+		}
+		req := finalityRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		now := time.Now()
+		items := []finalityItem{}
+		for kind, hashes := range map[string][]string{"tx": req.Transactions, "bundle": req.Bundles} {
+			for _, hash := range hashes {
+				item, err := f.register(hash, kind, now)
+				if err == errFinalityFull {
+					writeError(rw, http.StatusServiceUnavailable, err.Error())
+					return
+				} else if err != nil {
+					writeError(rw, http.StatusBadRequest, err.Error())
+					return
+				}
+				items = append(items, *item)
+			}
+		}
+		writeJSON(rw, http.StatusAccepted, items)
+
+	default:
+		writeError(rw, http.StatusMethodNotAllowed, "use GET or POST")
+	}
+}
+
+// handleFinalityHash returns the status of a single item.
+func (f *finalityTracker) handleFinalityHash(rw http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, "/api/v1/finality/")
+
+	f.Lock()
+	item, ok := f.items[hash]
+	var status finalityItem
+	if ok {
+		status = *item
+	}
+	f.Unlock()
+
+	if !ok {
+		writeError(rw, http.StatusNotFound, "hash is not tracked")
+		return
+	}
+	writeJSON(rw, http.StatusOK, status)
+}
+
+func initFinality(e *exporter) {
+	f := newFinalityTracker(e, *finalityMax, *finalityTimeout, *finalityRetention, *finalityWebhook)
+	e.finality = f
+
+	if *finalityCheckInterval > 0 {
+		go f.checkInclusionLoop(e.iriAddress, *finalityCheckInterval)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/iotaledger/giota"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFinalityTracker(t *testing.T) {
+
+	hooks := make(chan finalityItem, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		item := finalityItem{}
+		json.NewDecoder(r.Body).Decode(&item) // nolint: errcheck
+		hooks <- item
+	}))
+	defer webhook.Close()
+
+	e := newExporter("")
+	f := newFinalityTracker(e, 3, time.Hour, time.Hour, webhook.URL)
+	start := time.Now()
+
+	tx, bundle := syntheticHash("TX", 1), syntheticHash("BUNDLE", 1)
+	if _, err := f.register(tx, "tx", start); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.register(bundle, "bundle", start); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.register("INVALID", "tx", start); err == nil {
+		t.Errorf("Expected an error for an invalid hash")
+	}
+
+	f.confirmed(&sn{Hash: tx, Bundle: syntheticHash("OTHER", 1), Index: "100"}, start.Add(time.Minute))
+	select {
+	case item := <-hooks:
+		if item.Hash != tx || item.Status != "confirmed" || item.Milestone != 100 {
+			t.Errorf("Test webhook: Expected %v confirmed by milestone 100, got %+v", tx, item)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Test webhook: Expected a call")
+	}
+
+	// The bundle is confirmed through the getInclusionStates fallback
+	f.seen(&transaction{Hash: syntheticHash("BTX", 1), Bundle: bundle})
+	iri := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req) // nolint: errcheck
+		switch req["command"] {
+		case "getNodeInfo":
+			json.NewEncoder(w).Encode(giota.GetNodeInfoResponse{LatestMilestone: "MILESTONE", LatestMilestoneIndex: 101}) // nolint: errcheck
+		case "getInclusionStates":
+			json.NewEncoder(w).Encode(giota.GetInclusionStatesResponse{States: []bool{true}}) // nolint: errcheck
+		}
+	}))
+	defer iri.Close()
+
+	if err := f.checkInclusion(giota.NewAPI(iri.URL, nil), start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	<-hooks
+	if item := f.items[bundle]; item.Status != "confirmed" || item.Source != "inclusion" {
+		t.Errorf("Test inclusion: Expected the bundle to be confirmed by inclusion, got %+v", item)
+	}
+
+	f.register(syntheticHash("TX", 2), "tx", start) // nolint: errcheck
+	counts := f.expire(start.Add(90 * time.Minute))
+	if counts["confirmed"] != 0 || counts["expired"] != 1 {
+		t.Errorf("Test expire: Expected only 1 expired item, got %v", counts)
+	}
+}
+
+func TestCollectFinalityGolden(t *testing.T) {
+
+	e := newExporter("")
+	e.finality = newFinalityTracker(e, 10, time.Hour, time.Hour, "")
+	now := time.Now()
+
+	// One pending, one confirmed after two minutes and one expired item
+	for _, hash := range []string{syntheticHash("TX", 1), syntheticHash("TX", 2)} {
+		if _, err := e.finality.register(hash, "tx", now.Add(-10*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := e.finality.register(syntheticHash("BUNDLE", 1), "bundle", now.Add(-90*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	e.finality.confirmed(&sn{Hash: syntheticHash("TX", 2), Index: "400001"}, now.Add(-8*time.Minute))
+	scrapeFinality(e)
+
+	checkGolden(t, "finality", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectFinality(e, ch)
+	})))
+}
@@ -0,0 +1,195 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/pebbe/zmq4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata.")
+
+// fakeIRIResponse is the scripted answer of the fake IRI to a command. A
+// zero status answers 200 OK, delay holds the answer back to mimic a slow
+// node.
+type fakeIRIResponse struct {
+	status int
+	body   interface{}
+	delay  time.Duration
+}
+
+// fakeIRI is an IRI API stand-in answering commands with scripted responses.
+type fakeIRI struct {
+	*httptest.Server
+
+	sync.Mutex
+	responses map[string]fakeIRIResponse
+	calls     map[string]int
+}
+
+func newFakeIRI() *fakeIRI {
+	f := &fakeIRI{
+		responses: map[string]fakeIRIResponse{},
+		calls:     map[string]int{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// script sets the response to a command.
+func (f *fakeIRI) script(command string, resp fakeIRIResponse) {
+	f.Lock()
+	defer f.Unlock()
+	f.responses[command] = resp
+}
+
+// called returns how often a command was sent.
+func (f *fakeIRI) called(command string) int {
+	f.Lock()
+	defer f.Unlock()
+	return f.calls[command]
+}
+
+func (f *fakeIRI) serve(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Command string `json:"command"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON syntax"})
+		return
+	}
+
+	f.Lock()
+	f.calls[req.Command]++
+	resp, ok := f.responses[req.Command]
+	f.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Command [%s] is unknown", req.Command)})
+		return
+	}
+	time.Sleep(resp.delay)
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	writeJSON(w, resp.status, resp.body)
+}
+
+// fakeZmqPublisher is a local stand-in for the ZMQ publisher of IRI.
+type fakeZmqPublisher struct {
+	address string
+	socket  *zmq4.Socket
+}
+
+func newFakeZmqPublisher(t *testing.T) *fakeZmqPublisher {
+	// Reserve a free port, ZMQ binds it again right after.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "tcp://" + l.Addr().String()
+	l.Close()
+
+	socket, err := zmq4.NewSocket(zmq4.PUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := socket.Bind(address); err != nil {
+		t.Fatal(err)
+	}
+	return &fakeZmqPublisher{address: address, socket: socket}
+}
+
+func (p *fakeZmqPublisher) publish(msg string) error {
+	_, err := p.socket.Send(msg, 0)
+	return err
+}
+
+func (p *fakeZmqPublisher) Close() error {
+	return p.socket.Close()
+}
+
+// collectorFunc turns a collect function of a subsystem into a collector.
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(f, ch)
+}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) {
+	f(ch)
+}
+
+// exposition returns the text exposition of the metrics of a collector.
+func exposition(t *testing.T, c prometheus.Collector) []byte {
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// checkGolden compares the output with testdata/<name>.golden, or writes
+// the golden file when the tests run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Test %v: Expected output\n%s\ngot\n%s", name, want, got)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// arrivalTime returns when the node received a transaction. IRI publishes
+// the arrival date in milliseconds; the time the message was received is
+// used when it is missing.
+func arrivalTime(tx *transaction, received time.Time) time.Time {
+	arrival := stoi(tx.ArrivalDate)
+	switch {
+	case arrival > 1e12:
+		return time.Unix(0, arrival*int64(time.Millisecond))
+	case arrival > 0:
+		return time.Unix(arrival, 0)
+	}
+	return received
+}
+
+func metricsLatency(e *exporter) {
+
+	e.iotaZmqArrivalDelay = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_arrival_delay_seconds",
+			Name:    "iota_zmq_arrival_delay_seconds",
+			Help:    "Seconds from the timestamp of a transaction until it arrived at the node.",
+			Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600, 1800},
+		})
+
+	e.iotaZmqTimestampAnomalies = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_timestamp_anomalies_total",
+			Name: "iota_zmq_timestamp_anomalies_total",
+			Help: "Transactions with a timestamp more than --zmq.timestamp-future ahead of or --zmq.timestamp-past behind their arrival.",
+		},
+		[]string{"direction"},
+	)
+}
+
+func describeLatency(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaZmqArrivalDelay.Desc()
+	e.iotaZmqTimestampAnomalies.Describe(ch)
+}
+
+func collectLatency(e *exporter, ch chan<- prometheus.Metric) {
+	ch <- e.iotaZmqArrivalDelay
+	e.iotaZmqTimestampAnomalies.Collect(ch)
+}
+
+// observeArrival measures the arrival delay of a transaction. Transactions
+// with a timestamp outside the thresholds are counted as anomalies instead;
+// small negative delays from clock skew are counted as no delay.
+func observeArrival(e *exporter, tx *transaction, received time.Time) {
+	timestamp := stoi(tx.Timestamp)
+	if timestamp <= 0 {
+		return
+	}
+
+	delay := arrivalTime(tx, received).Sub(time.Unix(timestamp, 0))
+	switch {
+	case *zmqTimestampFuture > 0 && -delay > *zmqTimestampFuture:
+		e.iotaZmqTimestampAnomalies.WithLabelValues("future").Inc()
+	case *zmqTimestampPast > 0 && delay > *zmqTimestampPast:
+		e.iotaZmqTimestampAnomalies.WithLabelValues("past").Inc()
+	case delay < 0:
+		e.iotaZmqArrivalDelay.Observe(0)
+	default:
+		e.iotaZmqArrivalDelay.Observe(delay.Seconds())
+	}
+}
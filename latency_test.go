@@ -0,0 +1,104 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObserveArrival(t *testing.T) {
+
+	*zmqTimestampFuture, *zmqTimestampPast = 5*time.Minute, 2*time.Hour
+	defer func() { *zmqTimestampFuture, *zmqTimestampPast = 0, 0 }()
+
+	e := newExporter("")
+	received := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+	ms := strconv.FormatInt(received.UnixNano()/int64(time.Millisecond), 10)
+
+	for _, offset := range []time.Duration{
+		3 * time.Second,    // delay of 3s
+		-30 * time.Second,  // clock skew, delay of 0s
+		-time.Hour,         // future
+		3 * time.Hour,      // past
+		4 * 24 * time.Hour, // past
+	} {
+		timestamp := strconv.FormatInt(received.Add(-offset).Unix(), 10)
+		observeArrival(e, &transaction{Timestamp: timestamp, ArrivalDate: ms}, received)
+	}
+	// Without an arrival date the receive time is used
+	observeArrival(e, &transaction{Timestamp: strconv.FormatInt(received.Add(-7*time.Second).Unix(), 10)}, received)
+
+	if c := testutil.ToFloat64(e.iotaZmqTimestampAnomalies.WithLabelValues("future")); c != 1 {
+		t.Errorf("Expected 1 future anomaly, got %v", c)
+	}
+	if c := testutil.ToFloat64(e.iotaZmqTimestampAnomalies.WithLabelValues("past")); c != 2 {
+		t.Errorf("Expected 2 past anomalies, got %v", c)
+	}
+
+	expected := `
+		# HELP iota_zmq_arrival_delay_seconds Seconds from the timestamp of a transaction until it arrived at the node.
+		# TYPE iota_zmq_arrival_delay_seconds histogram
+		iota_zmq_arrival_delay_seconds_bucket{le="0.5"} 1
+		iota_zmq_arrival_delay_seconds_bucket{le="1"} 1
+		iota_zmq_arrival_delay_seconds_bucket{le="2"} 1
+		iota_zmq_arrival_delay_seconds_bucket{le="5"} 2
+		iota_zmq_arrival_delay_seconds_bucket{le="10"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="20"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="30"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="60"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="120"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="300"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="600"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="1800"} 3
+		iota_zmq_arrival_delay_seconds_bucket{le="+Inf"} 3
+		iota_zmq_arrival_delay_seconds_sum 10
+		iota_zmq_arrival_delay_seconds_count 3
+	`
+	if err := testutil.CollectAndCompare(e.iotaZmqArrivalDelay, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectLatencyGolden(t *testing.T) {
+
+	*zmqTimestampFuture, *zmqTimestampPast = 5*time.Minute, 2*time.Hour
+	defer func() { *zmqTimestampFuture, *zmqTimestampPast = 0, 0 }()
+
+	e := newExporter("")
+	received := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{3 * time.Second, 90 * time.Second, -30 * time.Second, -time.Hour, 3 * time.Hour} {
+		timestamp := strconv.FormatInt(received.Add(-offset).Unix(), 10)
+		observeArrival(e, &transaction{Timestamp: timestamp}, received)
+	}
+
+	checkGolden(t, "latency", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectLatency(e, ch)
+	})))
+}
@@ -24,7 +24,13 @@ SOFTWARE.
 
 package main
 
-import "strconv"
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"strings"
+	"time"
+)
 
 func btoi(b bool) int {
 	if b {
@@ -33,6 +39,13 @@ func btoi(b bool) int {
 	return 0
 }
 
+func btoi64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func btof(b bool) float64 {
 	if b {
 		return 1
@@ -57,6 +70,41 @@ func stof(s string) float64 {
 	return f
 }
 
+// abs returns the absolute value of an amount of iota as a float.
+func abs(i int64) float64 {
+	if i < 0 {
+		return float64(-i)
+	}
+	return float64(i)
+}
+
+// isTrytes checks that s only contains the tryte alphabet 9A-Z.
+func isTrytes(s string) bool {
+	for _, c := range s {
+		if c != '9' && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// recordTimeLayout is the layout of the TxIn and TxConfirmed fields of a txRecord.
+const recordTimeLayout = "20060102150405"
+
+func recordTime(t time.Time) int64 {
+	return stoi(t.UTC().Format(recordTimeLayout))
+}
+
+// recordDuration returns the seconds between two txRecord timestamps.
+func recordDuration(from, to int64) float64 {
+	f, err1 := time.Parse(recordTimeLayout, strconv.FormatInt(from, 10))
+	t, err2 := time.Parse(recordTimeLayout, strconv.FormatInt(to, 10))
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return t.Sub(f).Seconds()
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
@@ -69,3 +117,51 @@ func must(err error) {
 func BytesToString(data []byte) string {
 	return string(data[:])
 }
+
+// parseBuckets converts a bucket definition from the command line into
+// histogram buckets. Accepted forms are a comma separated list of upper
+// bounds ("1,5,10"), exponential buckets ("exp:start,factor,count") and
+// linear buckets ("lin:start,width,count"). An empty definition returns
+// nil, which makes the client library fall back to its default buckets.
+// Native histograms are not available in the client library in use.
+func parseBuckets(def string) ([]float64, error) {
+	def = strings.TrimSpace(def)
+	if def == "" {
+		return nil, nil
+	}
+
+	kind := ""
+	if i := strings.Index(def, ":"); i >= 0 {
+		kind, def = def[:i], def[i+1:]
+	}
+
+	var values []float64
+	for _, f := range strings.Split(def, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket value %q", f)
+		}
+		values = append(values, v)
+	}
+
+	switch kind {
+	case "":
+		for i := 1; i < len(values); i++ {
+			if values[i] <= values[i-1] {
+				return nil, fmt.Errorf("buckets must be in increasing order")
+			}
+		}
+		return values, nil
+	case "exp":
+		if len(values) != 3 || values[0] <= 0 || values[1] <= 1 || values[2] < 1 {
+			return nil, fmt.Errorf("exponential buckets need a positive start, a factor above 1 and a count")
+		}
+		return prometheus.ExponentialBuckets(values[0], values[1], int(values[2])), nil
+	case "lin":
+		if len(values) != 3 || values[1] <= 0 || values[2] < 1 {
+			return nil, fmt.Errorf("linear buckets need a start, a positive width and a count")
+		}
+		return prometheus.LinearBuckets(values[0], values[1], int(values[2])), nil
+	}
+	return nil, fmt.Errorf("unknown bucket type %q", kind)
+}
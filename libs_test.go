@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type bucketTest struct {
+	def     string
+	buckets []float64
+	fails   bool
+}
+
+func TestParseBuckets(t *testing.T) {
+
+	tests := []bucketTest{
+		{def: "", buckets: nil},
+		{def: "300,600, 1200", buckets: []float64{300, 600, 1200}},
+		{def: "exp:10,2,4", buckets: []float64{10, 20, 40, 80}},
+		{def: "lin:1,1,3", buckets: []float64{1, 2, 3}},
+		{def: "600,300", fails: true},
+		{def: "exp:0,2,4", fails: true},
+		{def: "lin:1,0,3", fails: true},
+		{def: "log:1,2,3", fails: true},
+		{def: "1,two", fails: true},
+	}
+
+	for i := range tests {
+		b, err := parseBuckets(tests[i].def)
+		if tests[i].fails {
+			if err == nil {
+				t.Errorf("Test %v: Expected %q to fail, got %v", i, tests[i].def, b)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(b, tests[i].buckets) {
+			t.Errorf("Test %v: Expected %v, got %v (%v)", i, tests[i].buckets, b, err)
+		}
+	}
+}
+
+func TestRecordDuration(t *testing.T) {
+
+	// 11:59:00 to 12:05:00 is six minutes, not the 4600 the raw numbers differ by.
+	d := recordDuration(20180304115900, 20180304120500)
+	if d != 360 {
+		t.Errorf("Expected 360 seconds, got %v", d)
+	}
+}
@@ -0,0 +1,374 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/pebbe/zmq4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const tryteAlphabet = "9ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// loadConfirmation is an sn message the load generator publishes once due.
+type loadConfirmation struct {
+	due time.Time
+	msg string
+}
+
+// loadTx is a transaction of a generated bundle.
+type loadTx struct {
+	address string
+	value   int64
+}
+
+// loadGenerator builds tx, sn, lmi and rstat messages resembling the ZMQ
+// stream of a mainnet node. Transactions are published in bundles that
+// approve recent transactions. A share of the bundles moves value between
+// addresses, spending an input of an earlier bundle again now and then, and
+// some zero value transactions promote pending value bundles. A share of the
+// bundles is confirmed after a delay by the current milestone, which
+// advances every minute.
+type loadGenerator struct {
+	rand             *rand.Rand
+	valueRatio       float64
+	confirmRatio     float64
+	doubleSpendRatio float64
+	confirmDelay     time.Duration
+
+	addresses []string
+	tags      []string
+	recent    []string
+	tails     []string
+	spent     []string
+	queued    []string
+	pending   []loadConfirmation
+
+	milestone     int64
+	milestoneTime time.Time
+	stored        int64
+}
+
+func newLoadGenerator(seed int64, valueRatio, confirmRatio, doubleSpendRatio float64, confirmDelay time.Duration) *loadGenerator {
+	g := &loadGenerator{
+		rand:             rand.New(rand.NewSource(seed)),
+		valueRatio:       valueRatio,
+		confirmRatio:     confirmRatio,
+		doubleSpendRatio: doubleSpendRatio,
+		confirmDelay:     confirmDelay,
+		milestone:        1000000,
+	}
+	for i := 0; i < 1000; i++ {
+		g.addresses = append(g.addresses, g.trytes(81))
+	}
+	for i := 0; i < 20; i++ {
+		g.tags = append(g.tags, g.trytes(27))
+	}
+	for i := 0; i < 2; i++ {
+		g.recent = append(g.recent, g.trytes(81))
+	}
+	return g
+}
+
+func (g *loadGenerator) trytes(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = tryteAlphabet[g.rand.Intn(len(tryteAlphabet))]
+	}
+	return string(b)
+}
+
+func (g *loadGenerator) address() string {
+	return g.addresses[g.rand.Intn(len(g.addresses))]
+}
+
+// keep appends s to a list of at most max recent entries.
+func keep(list []string, s string, max int) []string {
+	list = append(list, s)
+	if len(list) > max {
+		list = list[1:]
+	}
+	return list
+}
+
+// valueBundle returns the transactions of a transfer: the output, the input
+// with the second half of its signature and the remainder, if any. The
+// values of a bundle add up to 0.
+func (g *loadGenerator) valueBundle() (txs []loadTx, doubleSpend bool) {
+	value := 1 + g.rand.Int63n(1000000000)
+	balance := value + g.rand.Int63n(value)
+	input := g.address()
+	if len(g.spent) > 0 && g.rand.Float64() < g.doubleSpendRatio {
+		input = g.spent[g.rand.Intn(len(g.spent))]
+		doubleSpend = true
+	} else {
+		g.spent = keep(g.spent, input, 100)
+	}
+
+	txs = []loadTx{{g.address(), value}, {input, -balance}, {input, 0}}
+	if balance > value {
+		txs = append(txs, loadTx{g.address(), balance - value})
+	}
+	return txs, doubleSpend
+}
+
+// zeroBundle returns the transactions of a zero value bundle.
+func (g *loadGenerator) zeroBundle() []loadTx {
+	txs := make([]loadTx, 1+g.rand.Intn(3))
+	for i := range txs {
+		txs[i].address = g.address()
+	}
+	return txs
+}
+
+// bundle returns the tx messages of a new bundle and schedules the
+// confirmation of all its transactions, unless it is a double spend.
+func (g *loadGenerator) bundle(now time.Time) []string {
+	var txs []loadTx
+	doubleSpend := false
+	if g.rand.Float64() < g.valueRatio {
+		txs, doubleSpend = g.valueBundle()
+	} else {
+		txs = g.zeroBundle()
+	}
+
+	bundle, tag := g.trytes(81), g.tags[g.rand.Intn(len(g.tags))]
+	hashes := make([]string, len(txs))
+	for i := range hashes {
+		hashes[i] = g.trytes(81)
+	}
+	confirm := !doubleSpend && g.rand.Float64() < g.confirmRatio
+
+	// Each transaction approves the next one of the bundle, the last one and
+	// the branches approve recent transactions. A zero value bundle promotes
+	// a pending value bundle now and then.
+	last := len(txs) - 1
+	trunk := g.recent[g.rand.Intn(len(g.recent))]
+	if txs[0].value == 0 && len(g.tails) > 0 && g.rand.Float64() < 0.1 {
+		trunk = g.tails[g.rand.Intn(len(g.tails))]
+	}
+	msgs := make([]string, len(txs))
+	for i := last; i >= 0; i-- {
+		branch := g.recent[g.rand.Intn(len(g.recent))]
+		if i < last {
+			trunk = hashes[i+1]
+		}
+		msgs[i] = fmt.Sprintf("tx %s %s %d %s %d %d %d %s %s %s %d", hashes[i], txs[i].address, txs[i].value,
+			tag, now.Unix(), i, last, bundle, trunk, branch, now.UnixNano()/1e6)
+		if confirm {
+			g.pending = append(g.pending, loadConfirmation{
+				due: now.Add(g.confirmDelay),
+				msg: fmt.Sprintf("%s %s %s %s %s", hashes[i], txs[i].address, trunk, branch, bundle),
+			})
+		}
+	}
+
+	for _, hash := range hashes {
+		g.recent = keep(g.recent, hash, 100)
+	}
+	if txs[0].value != 0 {
+		g.tails = keep(g.tails, hashes[0], 100)
+	}
+	g.stored += int64(len(txs))
+	return msgs
+}
+
+// tx returns the next transaction message, starting a new bundle when the
+// previous one is published.
+func (g *loadGenerator) tx(now time.Time) string {
+	if len(g.queued) == 0 {
+		g.queued = g.bundle(now)
+	}
+	msg := g.queued[0]
+	g.queued = g.queued[1:]
+	return msg
+}
+
+// due returns the messages due at now: an lmi message when the milestone
+// advances and the sn messages of transactions that confirm.
+func (g *loadGenerator) due(now time.Time) []string {
+	var msgs []string
+	if g.milestoneTime.IsZero() {
+		g.milestoneTime = now
+	} else if now.Sub(g.milestoneTime) >= time.Minute {
+		msgs = append(msgs, fmt.Sprintf("lmi %d %d", g.milestone, g.milestone+1))
+		g.milestone++
+		g.milestoneTime = now
+	}
+
+	n := 0
+	for n < len(g.pending) && !g.pending[n].due.After(now) {
+		msgs = append(msgs, fmt.Sprintf("sn %d %s", g.milestone, g.pending[n].msg))
+		n++
+	}
+	g.pending = g.pending[n:]
+	return msgs
+}
+
+// rstat returns a queue statistics message.
+func (g *loadGenerator) rstat() string {
+	return fmt.Sprintf("rstat %d %d %d %d %d", g.rand.Intn(50), g.rand.Intn(50), g.rand.Intn(500), g.rand.Intn(10), g.stored)
+}
+
+// loadBenchmark reports how the exporter in this process keeps up with the
+// generated load.
+type loadBenchmark struct {
+	e        *exporter
+	start    time.Time
+	received float64
+	maxLag   float64
+}
+
+func newLoadBenchmark(address string) *loadBenchmark {
+	// Keep the benchmark away from the database and checkpoint of a real exporter.
+	*databaseBackend = "memory"
+	*zmqCheckpointInterval = 0
+
+	e := newExporter("")
+	initZmq(e, &address)
+
+	zmqAccumsLock.Lock()
+	defer zmqAccumsLock.Unlock()
+	return &loadBenchmark{e: e, start: time.Now(), received: zmqAccums.txTotal}
+}
+
+// gatheredValue returns the sum of the values of a counter or gauge collector.
+func gatheredValue(c prometheus.Collector) float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		return 0
+	}
+
+	var sum float64
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			sum += m.GetCounter().GetValue() + m.GetGauge().GetValue()
+		}
+	}
+	return sum
+}
+
+func (b *loadBenchmark) report(published int64) string {
+	zmqAccumsLock.Lock()
+	received := zmqAccums.txTotal - b.received
+	zmqAccumsLock.Unlock()
+
+	b.e.zmq.observeLag(time.Now())
+	lag := gatheredValue(b.e.iotaZmqQueueLag)
+	if lag > b.maxLag {
+		b.maxLag = lag
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return fmt.Sprintf("%v: published %d tx, received %.0f tx, queue %d, lag %.3fs (max %.3fs), dropped %.0f, heap %.1f MiB, sys %.1f MiB",
+		time.Since(b.start).Truncate(time.Second), published, received, len(b.e.zmq.queue), lag, b.maxLag,
+		gatheredValue(b.e.iotaZmqDroppedMessages), float64(mem.HeapAlloc)/(1<<20), float64(mem.Sys)/(1<<20))
+}
+
+// runLoadgen publishes the messages of g on address at rate transactions
+// per second until duration passed or it is interrupted. With benchmark set
+// the exporter pipeline runs in this process and its progress is reported
+// every interval.
+func runLoadgen(g *loadGenerator, address string, rate int, duration time.Duration, benchmark bool, interval time.Duration) error {
+	socket, err := zmq4.NewSocket(zmq4.PUB)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	if err := socket.Bind(address); err != nil {
+		return err
+	}
+	log.Infof("Publishing %d tx/s on %s.", rate, address)
+
+	var bench *loadBenchmark
+	if benchmark {
+		bench = newLoadBenchmark(strings.Replace(address, "*", "127.0.0.1", 1))
+		defer stopZmq(bench.e)
+		// Give the subscriber time to connect before the first message.
+		time.Sleep(time.Second)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	var deadline <-chan time.Time
+	if duration > 0 {
+		deadline = time.After(duration)
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	stats := time.NewTicker(time.Second)
+	defer stats.Stop()
+	reports := time.NewTicker(interval)
+	defer reports.Stop()
+
+	start := time.Now()
+	var published int64
+	for {
+		select {
+		case <-sig:
+		case <-deadline:
+		case now := <-ticker.C:
+			msgs := g.due(now)
+			for due := int64(now.Sub(start).Seconds() * float64(rate)); published < due; published++ {
+				msgs = append(msgs, g.tx(now))
+			}
+			for _, msg := range msgs {
+				if _, err := socket.Send(msg, 0); err != nil {
+					return err
+				}
+			}
+			continue
+		case <-stats.C:
+			if _, err := socket.Send(g.rstat(), 0); err != nil {
+				return err
+			}
+			continue
+		case <-reports.C:
+			if bench != nil {
+				log.Info(bench.report(published))
+			}
+			continue
+		}
+		break
+	}
+
+	if bench != nil {
+		log.Info(bench.report(published))
+	}
+	log.Infof("Published %d tx in %v.", published, time.Since(start).Truncate(time.Second))
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadGenerator(t *testing.T) {
+
+	g := newLoadGenerator(1, 0.5, 1, 0, time.Minute)
+	start := time.Unix(1530000000, 0)
+
+	if due := g.due(start); len(due) != 0 {
+		t.Errorf("Expected no messages due at the start, got %v", due)
+	}
+	var msgs []string
+	for n := 0; n < 100; n++ {
+		msgs = append(msgs, g.tx(start))
+	}
+	if due := g.due(start.Add(30 * time.Second)); len(due) != 0 {
+		t.Errorf("Expected no confirmations before the delay, got %v", len(due))
+	}
+	// Every transaction of the bundles started so far is confirmed, including
+	// the ones of the last bundle that are not published yet.
+	confirmed := 100 + len(g.queued)
+	due := g.due(start.Add(time.Minute))
+	if len(due) != confirmed+1 || !strings.HasPrefix(due[0], "lmi 1000000 1000001") {
+		t.Fatalf("Expected a new milestone and %v confirmations, got %v messages", confirmed, len(due))
+	}
+	msgs = append(msgs, due[1:]...)
+	msgs = append(msgs, g.rstat())
+
+	for i, msg := range msgs {
+		parts := strings.Fields(msg)
+		if want := map[string]int{"tx": 12, "sn": 7, "rstat": 6}[parts[0]]; len(parts) != want {
+			t.Errorf("Test %v: Expected %v fields in %q, got %v", i, want, parts[0], len(parts))
+		}
+	}
+
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 1000, "drop-newest")
+	for _, msg := range msgs {
+		handleZmqMessage(e, msg)
+	}
+	if len(p.queue) != 100+confirmed {
+		t.Errorf("Expected 100 tx and %v sn messages queued, got %v", confirmed, len(p.queue))
+	}
+}
+
+func TestLoadGeneratorBundles(t *testing.T) {
+
+	g := newLoadGenerator(1, 0.5, 1, 0.2, time.Minute)
+	start := time.Unix(1530000000, 0)
+	g.due(start)
+	var msgs []string
+	for n := 0; n < 1000 || len(g.queued) > 0; n++ {
+		msgs = append(msgs, g.tx(start))
+	}
+
+	// The values of every bundle add up to 0
+	sums := make(map[string]int64)
+	for _, msg := range msgs {
+		parts := strings.Fields(msg)
+		value, _ := strconv.ParseInt(parts[3], 10, 64)
+		sums[parts[8]] += value
+	}
+	for bundle, sum := range sums {
+		if sum != 0 {
+			t.Errorf("Test %v: Expected the values to add up to 0, got %v", bundle, sum)
+		}
+	}
+
+	e, _ := newTestPipeline(t, newMemoryStore(10000, testRetention), 10000, "drop-newest")
+	e.bundles = newBundleTracker(e, 0, time.Hour)
+	e.conflicts = newConflictDetector(e, 0, time.Hour)
+	for _, msg := range msgs {
+		handleZmqMessage(e, msg)
+	}
+	for _, msg := range g.due(start.Add(time.Minute)) {
+		handleZmqMessage(e, msg)
+	}
+
+	if c := testutil.ToFloat64(e.iotaZmqBundles.WithLabelValues("complete")); c != float64(len(sums)) {
+		t.Errorf("Expected %v complete bundles, got %v", len(sums), c)
+	}
+	if c := testutil.ToFloat64(e.iotaZmqPromotions); c == 0 {
+		t.Errorf("Expected promotions of pending value bundles")
+	}
+	if c := testutil.ToFloat64(e.iotaZmqConflicts); c == 0 {
+		t.Errorf("Expected double spends")
+	}
+	if c := testutil.ToFloat64(e.iotaZmqConflictsConfirmed); c == 0 {
+		t.Errorf("Expected the first spend of a double spend to be confirmed")
+	}
+}
+
+func TestGatheredValue(t *testing.T) {
+
+	e := newExporter("")
+	e.iotaZmqDroppedMessages.WithLabelValues("tx").Add(3)
+	e.iotaZmqDroppedMessages.WithLabelValues("sn").Add(2)
+	e.iotaZmqQueueLag.Set(0.5)
+
+	if v := gatheredValue(e.iotaZmqDroppedMessages); v != 5 {
+		t.Errorf("Expected 5 dropped messages, got %v", v)
+	}
+	if v := gatheredValue(e.iotaZmqQueueLag); v != 0.5 {
+		t.Errorf("Expected a lag of 0.5, got %v", v)
+	}
+}
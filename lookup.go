@@ -0,0 +1,203 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bloomFilter is a fixed size set that may report false positives but no
+// false negatives.
+type bloomFilter struct {
+	bits []uint64
+	k    uint64
+	n    int
+}
+
+// newBloomFilter sizes a filter for capacity keys with a false positive
+// rate of fp.
+func newBloomFilter(capacity int, fp float64) *bloomFilter {
+	m := math.Ceil(-float64(capacity) * math.Log(fp) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(capacity)*math.Ln2))
+	return &bloomFilter{bits: make([]uint64, int(m)/64+1), k: uint64(k)}
+}
+
+// positions derives the k bit positions of a key from two hashes.
+func (b *bloomFilter) positions(key string) []uint64 {
+	h := fnv.New128a()
+	h.Write([]byte(key)) // nolint: errcheck
+	sum := h.Sum(nil)
+	h1, h2 := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+
+	m := uint64(len(b.bits) * 64)
+	positions := make([]uint64, b.k)
+	for i := uint64(0); i < b.k; i++ {
+		positions[i] = (h1 + i*h2) % m
+	}
+	return positions
+}
+
+func (b *bloomFilter) add(key string) {
+	for _, p := range b.positions(key) {
+		b.bits[p/64] |= 1 << (p % 64)
+	}
+	b.n++
+}
+
+func (b *bloomFilter) contains(key string) bool {
+	for _, p := range b.positions(key) {
+		if b.bits[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// seenFilter remembers the hashes of recent transactions in two bloom
+// filters. Once the current filter is full or older than window it replaces
+// the previous one, so hashes are remembered for at least one window.
+type seenFilter struct {
+	sync.Mutex
+	capacity int
+	window   time.Duration
+	started  time.Time
+	current  *bloomFilter
+	previous *bloomFilter
+}
+
+func newSeenFilter(capacity int, window time.Duration, now time.Time) *seenFilter {
+	return &seenFilter{
+		capacity: capacity,
+		window:   window,
+		started:  now,
+		current:  newBloomFilter(capacity, 0.01),
+		previous: newBloomFilter(capacity, 0.01),
+	}
+}
+
+func (f *seenFilter) add(hash string, now time.Time) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.current.n >= f.capacity || now.Sub(f.started) >= f.window {
+		f.previous, f.current = f.current, newBloomFilter(f.capacity, 0.01)
+		f.started = now
+	}
+	f.current.add(hash)
+}
+
+func (f *seenFilter) contains(hash string) bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.current.contains(hash) || f.previous.contains(hash)
+}
+
+// txLookup is the answer of the transaction lookup endpoint. Stored
+// transactions include their record; transactions only found in the bloom
+// filter of zero value transactions were probably, but not certainly, seen.
+type txLookup struct {
+	Hash        string     `json:"hash"`
+	Seen        bool       `json:"seen"`
+	Stored      bool       `json:"stored"`
+	Probable    bool       `json:"probable,omitempty"`
+	FirstSeen   *time.Time `json:"firstSeen,omitempty"`
+	Confirmed   *time.Time `json:"confirmed,omitempty"`
+	Address     string     `json:"address,omitempty"`
+	Value       int64      `json:"value"`
+	Timestamp   int64      `json:"timestamp,omitempty"`
+	MilestoneIn int64      `json:"milestoneIn,omitempty"`
+	Category    string     `json:"category,omitempty"`
+}
+
+// recordTimeValue converts a txRecord timestamp to a time.
+func recordTimeValue(t int64) *time.Time {
+	if t == 0 {
+		return nil
+	}
+	v, err := time.Parse(recordTimeLayout, strconv.FormatInt(t, 10))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func lookupTx(store txStore, filter *seenFilter, hash string) (txLookup, error) {
+	lookup := txLookup{Hash: hash}
+
+	rec, err := store.Get(hash)
+	if err == nil {
+		lookup.Seen = true
+		lookup.Stored = true
+		lookup.FirstSeen = recordTimeValue(rec.TxIn)
+		lookup.Confirmed = recordTimeValue(rec.TxConfirmed)
+		lookup.Address = rec.TxAddress
+		lookup.Value = rec.TxValue
+		lookup.Timestamp = rec.Timestamp
+		lookup.MilestoneIn = rec.MilestoneIn
+		lookup.Category = rec.Category
+		return lookup, nil
+	} else if err != errTxNotFound {
+		return lookup, err
+	}
+
+	if filter != nil && filter.contains(hash) {
+		lookup.Seen = true
+		lookup.Probable = true
+	}
+	return lookup, nil
+}
+
+// handleTx looks up /api/v1/tx/{hash}.
+func handleTx(e *exporter) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, "/api/v1/tx/")
+		if !isTrytes(hash) || len(hash) != 81 {
+			writeError(rw, http.StatusBadRequest, "invalid transaction hash")
+			return
+		}
+
+		lookup, err := lookupTx(e.zmq.store, e.zeroValue, hash)
+		if err != nil {
+			writeError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if !lookup.Seen {
+			status = http.StatusNotFound
+		}
+		writeJSON(rw, status, lookup)
+	}
+}
+
+func initLookup(e *exporter) {
+	e.zeroValue = newSeenFilter(*lookupBloomCapacity, *lookupBloomWindow, time.Now())
+}
@@ -0,0 +1,105 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+
+	b := newBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		b.add(fmt.Sprintf("SEEN%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !b.contains(fmt.Sprintf("SEEN%d", i)) {
+			t.Fatalf("Expected SEEN%d to be in the filter", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if b.contains(fmt.Sprintf("OTHER%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Errorf("Expected about 1%% false positives, got %v in 10000", falsePositives)
+	}
+}
+
+func TestSeenFilterRotation(t *testing.T) {
+
+	now := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+	f := newSeenFilter(100, time.Hour, now)
+	f.add("A", now)
+	f.add("B", now.Add(time.Hour))
+	if !f.contains("A") || !f.contains("B") {
+		t.Errorf("Expected A and B after one rotation")
+	}
+	f.add("C", now.Add(2*time.Hour))
+	if f.contains("A") {
+		t.Errorf("Expected A to be forgotten after two rotations")
+	}
+}
+
+func TestTxLookup(t *testing.T) {
+
+	stored, zero, unknown := syntheticHash("STORED", 1), syntheticHash("ZERO", 1), syntheticHash("UNKNOWN", 1)
+	store := newMemoryStore(10, testRetention)
+	store.Put(stored, &txRecord{TxIn: 20180304120000, TxConfirmed: 20180304121000, TxAddress: "ADDR", TxValue: 5}) // nolint: errcheck
+
+	e := newExporter("")
+	e.zmq = &zmqPipeline{store: store}
+	e.zeroValue = newSeenFilter(100, time.Hour, time.Now())
+	e.zeroValue.add(zero, time.Now())
+
+	lookup, err := lookupTx(store, e.zeroValue, stored)
+	if err != nil || !lookup.Stored || lookup.Value != 5 || lookup.Confirmed == nil ||
+		!lookup.Confirmed.Equal(time.Date(2018, 3, 4, 12, 10, 0, 0, time.UTC)) {
+		t.Errorf("Test stored: Expected the stored record, got %+v %v", lookup, err)
+	}
+
+	for _, test := range []struct {
+		hash   string
+		status int
+	}{
+		{stored, http.StatusOK},
+		{zero, http.StatusOK},
+		{unknown, http.StatusNotFound},
+		{"NOTAHASH", http.StatusBadRequest},
+	} {
+		rw := httptest.NewRecorder()
+		handleTx(e)(rw, httptest.NewRequest("GET", "/api/v1/tx/"+test.hash, nil))
+		if rw.Code != test.status {
+			t.Errorf("Test %v: Expected status %v, got %v", test.hash, test.status, rw.Code)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// milestoneGroupIdle is how long no sn message must arrive for a milestone
+// before its statistics are complete.
+const milestoneGroupIdle = 30 * time.Second
+
+// milestoneGroup holds the statistics of the transactions confirmed by a
+// milestone. Only the value of the transactions seen by zeroMQ is known.
+type milestoneGroup struct {
+	index int64
+	txs   float64
+	seen  float64
+	value float64
+	last  time.Time
+}
+
+// milestoneGroups groups the sn messages by milestone index. The database
+// workers handle sn messages concurrently, so a group is complete once it was
+// idle for milestoneGroupIdle rather than when the next milestone starts.
+// Complete groups are observed on every record and scrape.
+type milestoneGroups struct {
+	sync.Mutex
+	groups map[int64]*milestoneGroup
+
+	confirmedTxs   prometheus.Histogram
+	confirmedValue prometheus.Histogram
+	seenRatio      prometheus.Histogram
+	lastSeenRatio  prometheus.Gauge
+}
+
+func newMilestoneGroups(e *exporter) *milestoneGroups {
+	return &milestoneGroups{
+		groups:         make(map[int64]*milestoneGroup),
+		confirmedTxs:   e.iotaMilestoneConfirmedTxs,
+		confirmedValue: e.iotaMilestoneConfirmedValue,
+		seenRatio:      e.iotaMilestoneSeenRatio,
+		lastSeenRatio:  e.iotaMilestoneLastSeenRatio,
+	}
+}
+
+// record adds a confirmed transaction to the group of its milestone.
+func (mg *milestoneGroups) record(index int64, seen bool, value int64, now time.Time) {
+	mg.add(index, seen, value, now)
+	mg.observe(now)
+}
+
+func (mg *milestoneGroups) add(index int64, seen bool, value int64, now time.Time) {
+	mg.Lock()
+	defer mg.Unlock()
+
+	g, ok := mg.groups[index]
+	if !ok {
+		g = &milestoneGroup{index: index}
+		mg.groups[index] = g
+	}
+	g.txs++
+	if seen {
+		g.seen++
+		if value > 0 {
+			g.value += float64(value)
+		}
+	}
+	g.last = now
+}
+
+// complete removes and returns the groups that are complete, in milestone
+// order.
+func (mg *milestoneGroups) complete(now time.Time) []milestoneGroup {
+	mg.Lock()
+	defer mg.Unlock()
+
+	var complete []milestoneGroup
+	for index, g := range mg.groups {
+		if now.Sub(g.last) >= milestoneGroupIdle {
+			complete = append(complete, *g)
+			delete(mg.groups, index)
+		}
+	}
+	sort.Slice(complete, func(i, j int) bool { return complete[i].index < complete[j].index })
+	return complete
+}
+
+// observe exports the statistics of the complete groups.
+func (mg *milestoneGroups) observe(now time.Time) {
+	for _, g := range mg.complete(now) {
+		mg.confirmedTxs.Observe(g.txs)
+		mg.confirmedValue.Observe(g.value)
+		mg.seenRatio.Observe(g.seen / g.txs)
+		mg.lastSeenRatio.Set(g.seen / g.txs)
+	}
+}
+
+// milestoneTracker follows the coordinator progress. New milestone indexes
+// come from the ZMQ lmi and sn messages and from getNodeInfo, whichever
+// reports them first.
+type milestoneTracker struct {
+	sync.Mutex
+	index    int64
+	last     time.Time
+	interval prometheus.Histogram
+}
+
+func newMilestoneTracker(e *exporter, now time.Time) *milestoneTracker {
+	return &milestoneTracker{last: now, interval: e.iotaMilestoneInterval}
+}
+
+// observe records a milestone index. The interval is only measured between
+// consecutive milestones, a gap means milestones were missed and the time
+// cannot be attributed to a single one.
+func (m *milestoneTracker) observe(index int64, now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	if index <= m.index {
+		return
+	}
+	if m.index > 0 && index == m.index+1 {
+		m.interval.Observe(now.Sub(m.last).Seconds())
+	}
+	m.index = index
+	m.last = now
+}
+
+// sinceLast returns the time since the last milestone, or since the exporter
+// started when no milestone was seen yet.
+func (m *milestoneTracker) sinceLast(now time.Time) time.Duration {
+	m.Lock()
+	defer m.Unlock()
+	return now.Sub(m.last)
+}
+
+func metricsMilestones(e *exporter) {
+
+	e.iotaMilestoneInterval = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_interval_seconds",
+			Name:    "iota_milestone_interval_seconds",
+			Help:    "Seconds between consecutive milestones.",
+			Buckets: []float64{30, 60, 90, 120, 180, 240, 300, 450, 600, 900, 1800},
+		})
+
+	e.iotaMilestoneSinceLast = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_seconds_since_last",
+			Name: "iota_milestone_seconds_since_last",
+			Help: "Seconds since the last new milestone.",
+		})
+
+	e.iotaMilestoneStalled = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_stalled",
+			Name: "iota_milestone_stalled",
+			Help: "1 when no milestone arrived within --milestone.stall-threshold.",
+		})
+
+	e.iotaMilestoneConfirmedTxs = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_confirmed_txs",
+			Name:    "iota_milestone_confirmed_txs",
+			Help:    "Transactions confirmed per milestone according to the sn messages.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+		})
+
+	e.iotaMilestoneConfirmedValue = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_confirmed_value",
+			Name:    "iota_milestone_confirmed_value",
+			Help:    "Positive value in iota confirmed per milestone, of the transactions seen by zeroMQ.",
+			Buckets: prometheus.ExponentialBuckets(1, 10, 16),
+		})
+
+	e.iotaMilestoneSeenRatio = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_seen_ratio",
+			Name:    "iota_milestone_seen_ratio",
+			Help:    "Share of the transactions confirmed per milestone that were seen by zeroMQ before.",
+			Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+		})
+
+	e.iotaMilestoneLastSeenRatio = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "milestone",
+			//Name: "milestone_last_seen_ratio",
+			Name: "iota_milestone_last_seen_ratio",
+			Help: "Share of the transactions confirmed by the last complete milestone that were seen by zeroMQ before.",
+		})
+}
+
+func describeMilestones(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaMilestoneInterval.Desc()
+	ch <- e.iotaMilestoneSinceLast.Desc()
+	ch <- e.iotaMilestoneStalled.Desc()
+	ch <- e.iotaMilestoneConfirmedTxs.Desc()
+	ch <- e.iotaMilestoneConfirmedValue.Desc()
+	ch <- e.iotaMilestoneSeenRatio.Desc()
+	ch <- e.iotaMilestoneLastSeenRatio.Desc()
+}
+
+func collectMilestones(e *exporter, ch chan<- prometheus.Metric) {
+	ch <- e.iotaMilestoneInterval
+	ch <- e.iotaMilestoneSinceLast
+	ch <- e.iotaMilestoneStalled
+	ch <- e.iotaMilestoneConfirmedTxs
+	ch <- e.iotaMilestoneConfirmedValue
+	ch <- e.iotaMilestoneSeenRatio
+	ch <- e.iotaMilestoneLastSeenRatio
+}
+
+func scrapeMilestones(e *exporter) {
+	since := e.milestones.sinceLast(time.Now())
+	e.iotaMilestoneSinceLast.Set(since.Seconds())
+	e.iotaMilestoneStalled.Set(btof(*milestoneStallThreshold > 0 && since > *milestoneStallThreshold))
+
+	e.milestoneGroups.observe(time.Now())
+}
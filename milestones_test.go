@@ -0,0 +1,156 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMilestoneTracker(t *testing.T) {
+
+	*milestoneStallThreshold = 10 * time.Minute
+	defer func() { *milestoneStallThreshold = 0 }()
+
+	e := newExporter("")
+	start := time.Now().Add(-time.Hour)
+	e.milestones = newMilestoneTracker(e, start)
+
+	e.milestones.observe(100, start.Add(time.Minute))
+	e.milestones.observe(101, start.Add(3*time.Minute))
+	e.milestones.observe(101, start.Add(4*time.Minute)) // sn of the same milestone
+	e.milestones.observe(103, start.Add(6*time.Minute)) // 102 was missed
+	e.milestones.observe(104, start.Add(7*time.Minute))
+
+	scrapeMilestones(e)
+	if s := testutil.ToFloat64(e.iotaMilestoneStalled); s != 1 {
+		t.Errorf("Expected milestones to be stalled after 53 minutes, got %v", s)
+	}
+
+	expected := `
+		# HELP iota_milestone_interval_seconds Seconds between consecutive milestones.
+		# TYPE iota_milestone_interval_seconds histogram
+		iota_milestone_interval_seconds_bucket{le="30"} 0
+		iota_milestone_interval_seconds_bucket{le="60"} 1
+		iota_milestone_interval_seconds_bucket{le="90"} 1
+		iota_milestone_interval_seconds_bucket{le="120"} 2
+		iota_milestone_interval_seconds_bucket{le="180"} 2
+		iota_milestone_interval_seconds_bucket{le="240"} 2
+		iota_milestone_interval_seconds_bucket{le="300"} 2
+		iota_milestone_interval_seconds_bucket{le="450"} 2
+		iota_milestone_interval_seconds_bucket{le="600"} 2
+		iota_milestone_interval_seconds_bucket{le="900"} 2
+		iota_milestone_interval_seconds_bucket{le="1800"} 2
+		iota_milestone_interval_seconds_bucket{le="+Inf"} 2
+		iota_milestone_interval_seconds_sum 180
+		iota_milestone_interval_seconds_count 2
+	`
+	if err := testutil.CollectAndCompare(e.iotaMilestoneInterval, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	e.milestones.observe(105, time.Now())
+	scrapeMilestones(e)
+	if s := testutil.ToFloat64(e.iotaMilestoneStalled); s != 0 {
+		t.Errorf("Expected milestones not to be stalled, got %v", s)
+	}
+}
+
+func TestMilestoneGroups(t *testing.T) {
+
+	e := newExporter("")
+	start := time.Now().Add(-time.Minute)
+	mg := e.milestoneGroups
+	mg.record(200, true, 1000, start)
+	mg.record(200, true, -1000, start)
+	mg.record(200, false, 0, start)
+	mg.record(200, true, 0, start)
+	mg.record(201, false, 0, time.Now())
+
+	if r := testutil.ToFloat64(e.iotaMilestoneLastSeenRatio); r != 0.75 {
+		t.Errorf("Expected 3 of 4 transactions of milestone 200 to be seen, got %v", r)
+	}
+	if n := len(mg.complete(time.Now())); n != 0 {
+		t.Errorf("Expected milestone 201 not to be complete, got %v complete groups", n)
+	}
+	if n := len(mg.complete(time.Now().Add(time.Minute))); n != 1 {
+		t.Errorf("Expected milestone 201 to be complete after a minute, got %v complete groups", n)
+	}
+
+	expected := `
+		# HELP iota_milestone_confirmed_value Positive value in iota confirmed per milestone, of the transactions seen by zeroMQ.
+		# TYPE iota_milestone_confirmed_value histogram
+		iota_milestone_confirmed_value_bucket{le="1"} 0
+		iota_milestone_confirmed_value_bucket{le="10"} 0
+		iota_milestone_confirmed_value_bucket{le="100"} 0
+		iota_milestone_confirmed_value_bucket{le="1000"} 1
+		iota_milestone_confirmed_value_bucket{le="10000"} 1
+		iota_milestone_confirmed_value_bucket{le="100000"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+06"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+07"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+08"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+09"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+10"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+11"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+12"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+13"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+14"} 1
+		iota_milestone_confirmed_value_bucket{le="1e+15"} 1
+		iota_milestone_confirmed_value_bucket{le="+Inf"} 1
+		iota_milestone_confirmed_value_sum 1000
+		iota_milestone_confirmed_value_count 1
+	`
+	if err := testutil.CollectAndCompare(e.iotaMilestoneConfirmedValue, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectMilestonesGolden(t *testing.T) {
+
+	e := newExporter("")
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// Two intervals are measured, the gap from 400001 to 400003 is not
+	m := newMilestoneTracker(e, start)
+	m.observe(400000, start)
+	m.observe(400001, start.Add(90*time.Second))
+	m.observe(400003, start.Add(5*time.Minute))
+	m.observe(400004, start.Add(6*time.Minute))
+
+	mg := e.milestoneGroups
+	mg.add(400001, true, 1500, start)
+	mg.add(400001, true, -1500, start)
+	mg.add(400001, false, 0, start)
+	mg.add(400001, false, 0, start)
+	mg.add(400002, true, 0, start.Add(time.Minute))
+	mg.observe(start.Add(2 * time.Minute))
+
+	checkGolden(t, "milestones", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectMilestones(e, ch)
+	})))
+}
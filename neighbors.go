@@ -148,7 +148,7 @@ func scrapeNeighbors(e *exporter, api *giota.API) {
 		neighborCount := len(resp2.Neighbors)
 		e.iotaNeighborsInfoTotalNeighbors.Set(float64(neighborCount))
 		e.iotaNeighborsInfoActiveNeighbors.Set(getActiveNeighbors(resp2.Neighbors))
-		for n := 1; n < neighborCount; n++ {
+		for n := 0; n < neighborCount; n++ {
 			address := string(resp2.Neighbors[n].Address)
 			e.iotaNeighborsActive.WithLabelValues(address).Set(
 				float64(getActiveNeighbor(address)))
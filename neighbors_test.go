@@ -0,0 +1,47 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/iotaledger/giota"
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+)
+
+func TestScrapeNeighbors(t *testing.T) {
+
+	iri := newFakeIRI()
+	defer iri.Close()
+	iri.script("getNeighbors", fakeIRIResponse{body: testNeighbors})
+
+	// The activity of neighbors is tracked globally across scrapes.
+	neighbormatrix = neighborMatrix{}
+	e := newExporter(iri.URL)
+	scrapeNeighbors(e, giota.NewAPI(iri.URL, nil))
+
+	checkGolden(t, "neighbors", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectNeighbors(e, ch)
+	})))
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/iotaledger/giota"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
+	"time"
 )
 
 func metricsNodeinfo(e *exporter) {
@@ -160,6 +161,8 @@ func scrapeNodeinfo(e *exporter, api *giota.API) {
 		e.iotaNodeInfoMaxMemory.Set(float64(resp.JREMaxMemory))
 		e.iotaNodeInfoTotalMemory.Set(float64(resp.JRETotalMemory))
 		e.iotaNodeInfoLatestMilestone.Set(float64(resp.LatestMilestoneIndex))
+		observeLatestMilestone(resp.LatestMilestoneIndex)
+		e.milestones.observe(resp.LatestMilestoneIndex, time.Now())
 		e.iotaNodeInfoLatestSubtangleMilestone.Set(float64(resp.LatestSolidSubtangleMilestoneIndex))
 		e.iotaNodeInfoTotalNeighbors.Set(float64(resp.Neighbors))
 		e.iotaNodeInfoTotalTips.Set(float64(resp.Tips))
@@ -0,0 +1,163 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/iotaledger/giota"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNodeInfo = giota.GetNodeInfoResponse{
+	AppName:                            "IRI",
+	AppVersion:                         "1.5.0",
+	Duration:                           2,
+	JREAvailableProcessors:             4,
+	JREFreeMemory:                      1000000,
+	JREMaxMemory:                       4000000,
+	JRETotalMemory:                     2000000,
+	LatestMilestoneIndex:               400010,
+	LatestSolidSubtangleMilestoneIndex: 400009,
+	Neighbors:                          2,
+	Tips:                               5000,
+	TransactionsToRequest:              12,
+}
+
+var testNeighbors = giota.GetNeighborsResponse{
+	Duration: 1,
+	Neighbors: []giota.Neighbor{
+		{Address: "node-a.example.com:15600", ConnectionType: "tcp", NumberOfAllTransactions: 1000,
+			NumberOfInvalidTransactions: 1, NumberOfNewTransactions: 300, NumberOfRandomTransactionRequests: 20, NumberOfSentTransactions: 900},
+		{Address: "node-b.example.com:15600", ConnectionType: "udp", NumberOfAllTransactions: 2000,
+			NumberOfInvalidTransactions: 2, NumberOfNewTransactions: 600, NumberOfRandomTransactionRequests: 40, NumberOfSentTransactions: 1800},
+	},
+}
+
+func TestScrapeNodeinfo(t *testing.T) {
+
+	iri := newFakeIRI()
+	defer iri.Close()
+	iri.script("getNodeInfo", fakeIRIResponse{body: testNodeInfo})
+
+	e := newExporter(iri.URL)
+	scrapeNodeinfo(e, giota.NewAPI(iri.URL, nil))
+
+	checkGolden(t, "nodeinfo", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectNodeinfo(e, ch)
+	})))
+}
+
+func TestScrapeNodeinfoErrors(t *testing.T) {
+
+	tests := []fakeIRIResponse{
+		{status: http.StatusInternalServerError, body: map[string]string{"exception": "java.lang.OutOfMemoryError"}},
+		{status: http.StatusBadRequest, body: map[string]string{"error": "Invalid API Version"}},
+		{status: http.StatusServiceUnavailable, body: "busy"},
+	}
+
+	for i, resp := range tests {
+		iri := newFakeIRI()
+		iri.script("getNodeInfo", resp)
+
+		e := newExporter(iri.URL)
+		scrapeNodeinfo(e, giota.NewAPI(iri.URL, nil))
+		iri.Close()
+
+		if s := testutil.ToFloat64(e.iotaNodeInfoTotalScrapes); s != 0 {
+			t.Errorf("Test %v: Expected a failed scrape not to be counted, got %v", i, s)
+		}
+		if m := testutil.ToFloat64(e.iotaNodeInfoLatestMilestone); m != 0 {
+			t.Errorf("Test %v: Expected the milestone not to be set, got %v", i, m)
+		}
+	}
+}
+
+func TestScrapeNodeinfoLatency(t *testing.T) {
+
+	iri := newFakeIRI()
+	defer iri.Close()
+	iri.script("getNodeInfo", fakeIRIResponse{body: testNodeInfo, delay: 100 * time.Millisecond})
+
+	e := newExporter(iri.URL)
+	start := time.Now()
+	scrapeNodeinfo(e, giota.NewAPI(iri.URL, nil))
+
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("Expected the scrape to wait for the slow node, took %v", d)
+	}
+	if m := testutil.ToFloat64(e.iotaNodeInfoLatestMilestone); m != 400010 {
+		t.Errorf("Expected milestone 400010, got %v", m)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+
+	iri := newFakeIRI()
+	defer iri.Close()
+	iri.script("getNodeInfo", fakeIRIResponse{body: testNodeInfo})
+	iri.script("getNeighbors", fakeIRIResponse{body: testNeighbors})
+
+	neighbormatrix = neighborMatrix{}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newExporter(iri.URL))
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if iri.called("getNodeInfo") != 1 || iri.called("getNeighbors") != 1 {
+		t.Errorf("Expected one getNodeInfo and getNeighbors call per scrape, got %v and %v",
+			iri.called("getNodeInfo"), iri.called("getNeighbors"))
+	}
+	for _, name := range []string{"nodeinfo", "neighbors"} {
+		want, err := ioutil.ReadFile("testdata/" + name + ".golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(want)), "\n") {
+			if !strings.Contains(string(body), line+"\n") {
+				t.Errorf("Test %v: Expected /metrics to contain %q", name, line)
+			}
+		}
+	}
+	if !strings.Contains(string(body), "iota_node_info_scrapes_total 1\n") {
+		t.Errorf("Expected /metrics to count the scrape")
+	}
+}
@@ -0,0 +1,227 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pendingAge is an age bucket of the pending value transactions.
+type pendingAge struct {
+	label string
+	max   time.Duration
+}
+
+var pendingAges = []pendingAge{
+	{"10m", 10 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+	{"older", 0},
+}
+
+// pendingTx is a value transaction that is not confirmed yet.
+type pendingTx struct {
+	Hash    string    `json:"hash"`
+	Address string    `json:"address"`
+	Value   int64     `json:"value"`
+	Seen    time.Time `json:"seen"`
+	Age     float64   `json:"ageSeconds"`
+}
+
+// pendingReport is the result of a scan of the database.
+type pendingReport struct {
+	Scanned  time.Time      `json:"scanned"`
+	Duration float64        `json:"durationSeconds"`
+	Counts   map[string]int `json:"counts"`
+	Value    float64        `json:"value"`
+	Oldest   []pendingTx    `json:"oldest"`
+}
+
+// pendingScanner periodically scans the database for value transactions that
+// are not confirmed, and keeps the last report.
+type pendingScanner struct {
+	sync.Mutex
+	store  txStore
+	keep   int
+	report pendingReport
+}
+
+// scan builds a report of the pending value transactions, keeping the keep
+// oldest ones.
+func scanPending(store txStore, keep int, now time.Time) (pendingReport, error) {
+	report := pendingReport{Scanned: now, Counts: make(map[string]int)}
+	for _, age := range pendingAges {
+		report.Counts[age.label] = 0
+	}
+
+	nowRecord := recordTime(now)
+	trim := func() {
+		sort.Slice(report.Oldest, func(i, j int) bool { return report.Oldest[i].Age > report.Oldest[j].Age })
+		if len(report.Oldest) > keep {
+			report.Oldest = report.Oldest[:keep]
+		}
+	}
+
+	err := store.ForEach(func(hash string, rec *txRecord) bool {
+		if rec.TxConfirmed != 0 || rec.TxValue == 0 {
+			return true
+		}
+
+		age := recordDuration(rec.TxIn, nowRecord)
+		for _, a := range pendingAges {
+			if a.max == 0 || age < a.max.Seconds() {
+				report.Counts[a.label]++
+				break
+			}
+		}
+		if rec.TxValue > 0 {
+			report.Value += float64(rec.TxValue)
+		}
+
+		report.Oldest = append(report.Oldest, pendingTx{
+			Hash:    hash,
+			Address: rec.TxAddress,
+			Value:   rec.TxValue,
+			Seen:    now.Add(-time.Duration(age) * time.Second),
+			Age:     age,
+		})
+		if len(report.Oldest) >= 2*keep {
+			trim()
+		}
+		return true
+	})
+	trim()
+	report.Duration = time.Since(now).Seconds()
+	return report, err
+}
+
+func (ps *pendingScanner) scan(now time.Time) error {
+	report, err := scanPending(ps.store, ps.keep, now)
+	if err != nil {
+		return err
+	}
+	ps.Lock()
+	ps.report = report
+	ps.Unlock()
+	return nil
+}
+
+// run scans the database every interval.
+func (ps *pendingScanner) run(interval time.Duration) {
+	for {
+		if err := ps.scan(time.Now()); err != nil {
+			log.Infof("Database error %v.", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// handlePending returns the last report. The limit query parameter limits
+// the number of transactions listed.
+func (ps *pendingScanner) handlePending(rw http.ResponseWriter, r *http.Request) {
+	ps.Lock()
+	report := ps.report
+	ps.Unlock()
+
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(report.Oldest) {
+		report.Oldest = report.Oldest[:limit]
+	}
+	writeJSON(rw, http.StatusOK, report)
+}
+
+func initPending(e *exporter) {
+	if *pendingScanInterval <= 0 {
+		return
+	}
+	e.pending = &pendingScanner{store: e.zmq.store, keep: *pendingListSize}
+	http.HandleFunc("/pending", e.pending.handlePending)
+	go e.pending.run(*pendingScanInterval)
+}
+
+func metricsPending(e *exporter) {
+
+	e.iotaZmqPendingTxs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_pending_value_txs",
+			Name: "iota_zmq_pending_value_txs",
+			Help: "Value transactions in the database that are not confirmed, by age (below 10m, 1h, 1d or older).",
+		},
+		[]string{"age"},
+	)
+
+	e.iotaZmqPendingValue = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_pending_value",
+			Name: "iota_zmq_pending_value",
+			Help: "Sum of the positive values in iota of the transactions in the database that are not confirmed.",
+		})
+
+	e.iotaZmqPendingScanDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_pending_scan_duration_seconds",
+			Name: "iota_zmq_pending_scan_duration_seconds",
+			Help: "Duration of the last scan for pending value transactions.",
+		})
+}
+
+func describePending(e *exporter, ch chan<- *prometheus.Desc) {
+	e.iotaZmqPendingTxs.Describe(ch)
+	ch <- e.iotaZmqPendingValue.Desc()
+	ch <- e.iotaZmqPendingScanDuration.Desc()
+}
+
+func collectPending(e *exporter, ch chan<- prometheus.Metric) {
+	e.iotaZmqPendingTxs.Collect(ch)
+	ch <- e.iotaZmqPendingValue
+	ch <- e.iotaZmqPendingScanDuration
+}
+
+func scrapePending(e *exporter) {
+	if e.pending == nil {
+		return
+	}
+
+	e.pending.Lock()
+	report := e.pending.report
+	e.pending.Unlock()
+
+	for label, count := range report.Counts {
+		e.iotaZmqPendingTxs.WithLabelValues(label).Set(float64(count))
+	}
+	e.iotaZmqPendingValue.Set(report.Value)
+	e.iotaZmqPendingScanDuration.Set(report.Duration)
+}
@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScanPending(t *testing.T) {
+
+	now := time.Now().UTC().Truncate(time.Second)
+	store := newMemoryStore(100, testRetention)
+
+	for hash, rec := range map[string]txRecord{
+		"NEW":       {TxIn: recordTime(now.Add(-time.Minute)), TxValue: 10},
+		"HOUR":      {TxIn: recordTime(now.Add(-30 * time.Minute)), TxValue: -10},
+		"DAY":       {TxIn: recordTime(now.Add(-2 * time.Hour)), TxValue: 20},
+		"OLD":       {TxIn: recordTime(now.Add(-48 * time.Hour)), TxValue: 30, TxAddress: "ADDR"},
+		"ZERO":      {TxIn: recordTime(now.Add(-48 * time.Hour))},
+		"CONFIRMED": {TxIn: recordTime(now.Add(-48 * time.Hour)), TxValue: 5, TxConfirmed: recordTime(now)},
+	} {
+		rec := rec
+		store.Put(hash, &rec) // nolint: errcheck
+	}
+
+	report, err := scanPending(store, 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for label, expected := range map[string]int{"10m": 1, "1h": 1, "1d": 1, "older": 1} {
+		if report.Counts[label] != expected {
+			t.Errorf("Test age %v: Expected %v, got %v", label, expected, report.Counts[label])
+		}
+	}
+	if report.Value != 60 {
+		t.Errorf("Test value: Expected 60, got %v", report.Value)
+	}
+	if len(report.Oldest) != 2 || report.Oldest[0].Hash != "OLD" || report.Oldest[0].Address != "ADDR" || report.Oldest[1].Hash != "DAY" {
+		t.Errorf("Test oldest: Expected OLD and DAY, got %+v", report.Oldest)
+	}
+
+	ps := &pendingScanner{report: report}
+	rw := httptest.NewRecorder()
+	ps.handlePending(rw, httptest.NewRequest("GET", "/pending?limit=1", nil))
+	listed := pendingReport{}
+	if err := json.NewDecoder(rw.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.Oldest) != 1 || listed.Oldest[0].Hash != "OLD" {
+		t.Errorf("Test limit: Expected only OLD, got %+v", listed.Oldest)
+	}
+}
+
+func TestCollectPendingGolden(t *testing.T) {
+
+	e := newExporter("")
+	e.pending = &pendingScanner{report: pendingReport{
+		Counts:   map[string]int{"10m": 3, "1h": 1, "1d": 0, "older": 2},
+		Value:    1500,
+		Duration: 0.25,
+	}}
+	scrapePending(e)
+
+	checkGolden(t, "pending", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectPending(e, ch)
+	})))
+}
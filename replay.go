@@ -0,0 +1,188 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/pebbe/zmq4"
+	"github.com/prometheus/common/log"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A ZMQ recording is a gzip compressed text file with a line per message:
+// the receive time in nanoseconds since the epoch, a space and the message.
+
+// zmqRecorder writes ZMQ messages to a recording.
+type zmqRecorder struct {
+	file io.Closer
+	gz   *gzip.Writer
+	w    *bufio.Writer
+}
+
+func newZmqRecorder(w io.WriteCloser) *zmqRecorder {
+	gz := gzip.NewWriter(w)
+	return &zmqRecorder{file: w, gz: gz, w: bufio.NewWriter(gz)}
+}
+
+func (r *zmqRecorder) write(received time.Time, msg string) error {
+	_, err := fmt.Fprintf(r.w, "%d %s\n", received.UnixNano(), strings.Replace(msg, "\n", " ", -1))
+	return err
+}
+
+// Close flushes the recording and closes the underlying file.
+func (r *zmqRecorder) Close() error {
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if err := r.gz.Close(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
+
+// readZmqRecording calls fn for every message of a recording.
+func readZmqRecording(r io.Reader, fn func(received time.Time, msg string) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		parts := strings.SplitN(scanner.Text(), " ", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("line %d: malformed recording", line)
+		}
+		ns, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := fn(time.Unix(0, ns), parts[1]); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// replayZmq feeds the messages of a recording to the exporter as if they
+// arrived from IRI, speed times faster than they were recorded. A speed of 0
+// replays as fast as possible. Receive times are the replay times, so
+// durations shrink with the speed.
+func replayZmq(e *exporter, file string, speed float64) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Infof("Replaying ZMQ messages from %s at %vx speed.", file, speed)
+	var first time.Time
+	start := time.Now()
+	n := 0
+	err = readZmqRecording(f, func(received time.Time, msg string) error {
+		if first.IsZero() {
+			first = received
+		}
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(received.Sub(first)) / speed))
+			time.Sleep(time.Until(due))
+		}
+		handleZmqMessage(e, msg)
+		n++
+		return nil
+	})
+	log.Infof("Replayed %d ZMQ messages in %v.", n, time.Since(start))
+	return err
+}
+
+// recordZmq writes the messages of the IRI ZMQ stream to a recording until
+// duration passed or the recorder is interrupted.
+func recordZmq(address, output string, duration time.Duration) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	recorder := newZmqRecorder(f)
+
+	socket, err := zmq4.NewSocket(zmq4.SUB)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	for _, topic := range zmqTopics {
+		if err := socket.SetSubscribe(topic); err != nil {
+			return err
+		}
+	}
+	if err := socket.SetRcvtimeo(time.Second); err != nil {
+		return err
+	}
+	if err := socket.Connect(address); err != nil {
+		return err
+	}
+	log.Infof("Recording ZMQ messages from %s to %s.", address, output)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	var deadline <-chan time.Time
+	if duration > 0 {
+		deadline = time.After(duration)
+	}
+
+	n := 0
+	for {
+		select {
+		case <-sig:
+		case <-deadline:
+		default:
+			msg, err := socket.Recv(0)
+			if err == zmq4.ETIMEDOUT {
+				continue
+			} else if err != nil {
+				recorder.Close() // nolint: errcheck
+				return err
+			}
+			if err := recorder.write(time.Now(), msg); err != nil {
+				return err
+			}
+			n++
+			continue
+		}
+		break
+	}
+
+	log.Infof("Recorded %d ZMQ messages.", n)
+	return recorder.Close()
+}
@@ -0,0 +1,134 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestRecording(t *testing.T, path string, msgs []string, start time.Time, gap time.Duration) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newZmqRecorder(f)
+	for i, msg := range msgs {
+		if err := r.write(start.Add(time.Duration(i)*gap), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZmqRecordingRoundTrip(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recording.gz")
+
+	msgs := []string{syntheticTxMsg(1, 0), syntheticSnMsg(1, 400001), "lmi 400000 400001"}
+	start := time.Unix(1530000000, 123456789)
+	writeTestRecording(t, path, msgs, start, time.Second)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []string
+	err = readZmqRecording(f, func(received time.Time, msg string) error {
+		if want := start.Add(time.Duration(len(got)) * time.Second); !received.Equal(want) {
+			t.Errorf("Test %v: Expected receive time %v, got %v", len(got), want, received)
+		}
+		got = append(got, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(msgs) {
+		t.Fatalf("Expected %d messages, got %d", len(msgs), len(got))
+	}
+	for i := range msgs {
+		if got[i] != msgs[i] {
+			t.Errorf("Test %v: Expected %q, got %q", i, msgs[i], got[i])
+		}
+	}
+}
+
+func TestZmqRecordingMalformed(t *testing.T) {
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("notatime tx\n"))
+	gz.Close()
+
+	err := readZmqRecording(&buf, func(time.Time, string) error { return nil })
+	if err == nil {
+		t.Errorf("Expected an error for a malformed recording")
+	}
+}
+
+func TestReplayZmq(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recording.gz")
+
+	var msgs []string
+	for n := 0; n < 10; n++ {
+		msgs = append(msgs, syntheticTxMsg(n, 0))
+	}
+	writeTestRecording(t, path, msgs, time.Now(), 100*time.Millisecond)
+
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 100, "drop-newest")
+
+	// 900ms of recording at 10x speed take about 90ms.
+	start := time.Now()
+	if err := replayZmq(e, path, 10); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 80*time.Millisecond || d > 2*time.Second {
+		t.Errorf("Expected the replay to take about 90ms, got %v", d)
+	}
+	if len(p.queue) != len(msgs) {
+		t.Errorf("Expected %d queued tx messages, got %d", len(msgs), len(p.queue))
+	}
+}
@@ -0,0 +1,310 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// spamEventsKept is the number of recent events listed by the API.
+	spamEventsKept = 100
+	// spamActiveMax is the maximum number of offending sources exported.
+	spamActiveMax = 20
+)
+
+// spamEvent is a source whose zero value rate exceeded its baseline.
+type spamEvent struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Source   string    `json:"source"`
+	Decoded  string    `json:"decoded,omitempty"`
+	Rate     float64   `json:"rate"`
+	Baseline float64   `json:"baseline"`
+}
+
+// spamSource is the EWMA baseline of the zero value rate of a tag or
+// address.
+type spamSource struct {
+	baseline float64
+	active   bool
+}
+
+// spamDetector compares the zero value transaction rate of the network and
+// of every tag and address with an exponentially weighted moving average.
+// Sources are flagged when their rate in an interval exceeds sensitivity
+// times their baseline, and at least minRate.
+type spamDetector struct {
+	sync.Mutex
+	interval    time.Duration
+	alpha       float64
+	sensitivity float64
+	minRate     float64
+	maxSources  int
+	warmup      int
+
+	count    float64
+	counts   map[string]map[string]float64
+	sources  map[string]map[string]*spamSource
+	baseline float64
+	score    float64
+	ticks    int
+	events   []spamEvent
+
+	eventsTotal *prometheus.CounterVec
+}
+
+func newSpamDetector(e *exporter, interval time.Duration, alpha, sensitivity, minRate float64, maxSources int) *spamDetector {
+	sd := &spamDetector{
+		interval:    interval,
+		alpha:       alpha,
+		sensitivity: sensitivity,
+		minRate:     minRate,
+		maxSources:  maxSources,
+		warmup:      int(1 / alpha),
+		counts:      make(map[string]map[string]float64),
+		sources:     make(map[string]map[string]*spamSource),
+		eventsTotal: e.iotaZmqSpamEvents,
+	}
+	for _, kind := range []string{"tag", "address"} {
+		sd.counts[kind] = make(map[string]float64)
+		sd.sources[kind] = make(map[string]*spamSource)
+	}
+	return sd
+}
+
+func metricsSpam(e *exporter) {
+
+	e.iotaZmqSpamScore = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_spam_score",
+			Name: "iota_zmq_spam_score",
+			Help: "Zero value transaction rate of the last interval divided by its moving average baseline.",
+		})
+
+	e.iotaZmqSpamEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_spam_events_total",
+			Name: "iota_zmq_spam_events_total",
+			Help: "Times a tag or address started sending zero value transactions above its baseline.",
+		},
+		[]string{"kind"},
+	)
+
+	e.iotaZmqSpamActive = prometheus.NewDesc(
+		"iota_zmq_spam_active_rate",
+		"Zero value transactions per second of the tags and addresses currently above their baseline.",
+		[]string{"kind", "source"}, nil,
+	)
+}
+
+func describeSpam(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaZmqSpamScore.Desc()
+	e.iotaZmqSpamEvents.Describe(ch)
+	ch <- e.iotaZmqSpamActive
+}
+
+func collectSpam(e *exporter, ch chan<- prometheus.Metric) {
+	ch <- e.iotaZmqSpamScore
+	e.iotaZmqSpamEvents.Collect(ch)
+
+	if e.spam == nil {
+		return
+	}
+	for _, ev := range e.spam.active() {
+		ch <- prometheus.MustNewConstMetric(e.iotaZmqSpamActive, prometheus.GaugeValue, ev.Rate, ev.Kind, ev.Source)
+	}
+}
+
+func scrapeSpam(e *exporter) {
+	if e.spam == nil {
+		return
+	}
+
+	e.spam.Lock()
+	score := e.spam.score
+	e.spam.Unlock()
+	e.iotaZmqSpamScore.Set(score)
+}
+
+// seen counts a zero value transaction in the current interval.
+func (sd *spamDetector) seen(tx *transaction) {
+	if tx.Value != 0 {
+		return
+	}
+
+	sd.Lock()
+	defer sd.Unlock()
+
+	sd.count++
+	sd.countSource("tag", tx.Tag)
+	sd.countSource("address", tx.Address)
+}
+
+func (sd *spamDetector) countSource(kind, source string) {
+	counts := sd.counts[kind]
+	if _, ok := counts[source]; !ok && len(counts) >= sd.maxSources {
+		return
+	}
+	counts[source]++
+}
+
+// tick closes an interval: it updates the baselines and flags the sources
+// above their baseline.
+func (sd *spamDetector) tick(now time.Time) []spamEvent {
+	sd.Lock()
+	defer sd.Unlock()
+
+	seconds := sd.interval.Seconds()
+	rate := sd.count / seconds
+	if sd.ticks == 0 {
+		sd.baseline = rate
+	}
+	if sd.baseline > 0 {
+		sd.score = rate / sd.baseline
+	}
+	sd.baseline += sd.alpha * (rate - sd.baseline)
+	sd.count = 0
+	sd.ticks++
+
+	var events []spamEvent
+	for kind, counts := range sd.counts {
+		sources := sd.sources[kind]
+
+		for source, count := range counts {
+			s, ok := sources[source]
+			if !ok {
+				if len(sources) >= sd.maxSources {
+					continue
+				}
+				s = &spamSource{}
+				sources[source] = s
+			}
+
+			rate := count / seconds
+			limit := sd.sensitivity * s.baseline
+			if limit < sd.minRate {
+				limit = sd.minRate
+			}
+			above := sd.ticks > sd.warmup && rate > limit
+			if above && !s.active {
+				ev := spamEvent{Time: now, Kind: kind, Source: source, Rate: rate, Baseline: s.baseline}
+				if kind == "tag" {
+					ev.Decoded = trytesToASCII(source)
+				}
+				events = append(events, ev)
+			}
+			s.active = above
+			// Offending traffic does not raise the baseline
+			if !above {
+				s.baseline += sd.alpha * (rate - s.baseline)
+			}
+		}
+
+		// Sources without traffic decay and are dropped once negligible
+		for source, s := range sources {
+			if _, ok := counts[source]; ok {
+				continue
+			}
+			s.active = false
+			s.baseline -= sd.alpha * s.baseline
+			if s.baseline < 0.01 {
+				delete(sources, source)
+			}
+		}
+		sd.counts[kind] = make(map[string]float64)
+	}
+
+	for _, ev := range events {
+		sd.eventsTotal.WithLabelValues(ev.Kind).Inc()
+		log.Warnf("Spam wave from %s %s (%s): %.1f zero value tx/s, baseline %.1f.", ev.Kind, ev.Source, ev.Decoded, ev.Rate, ev.Baseline)
+	}
+	sd.events = append(sd.events, events...)
+	if len(sd.events) > spamEventsKept {
+		sd.events = sd.events[len(sd.events)-spamEventsKept:]
+	}
+	return events
+}
+
+// active returns the sources currently above their baseline, highest rate
+// first.
+func (sd *spamDetector) active() []spamEvent {
+	sd.Lock()
+	defer sd.Unlock()
+
+	var active []spamEvent
+	listed := make(map[string]bool)
+	for i := len(sd.events) - 1; i >= 0; i-- {
+		ev := sd.events[i]
+		key := ev.Kind + "/" + ev.Source
+		if s, ok := sd.sources[ev.Kind][ev.Source]; ok && s.active && !listed[key] {
+			active = append(active, ev)
+			listed[key] = true
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Rate > active[j].Rate })
+	if len(active) > spamActiveMax {
+		active = active[:spamActiveMax]
+	}
+	return active
+}
+
+// run closes an interval every interval.
+func (sd *spamDetector) run() {
+	for now := range time.Tick(sd.interval) {
+		sd.tick(now)
+	}
+}
+
+func (sd *spamDetector) handleSpam(rw http.ResponseWriter, r *http.Request) {
+	sd.Lock()
+	events := make([]spamEvent, 0, len(sd.events))
+	for i := len(sd.events) - 1; i >= 0; i-- {
+		events = append(events, sd.events[i])
+	}
+	sd.Unlock()
+
+	writeJSON(rw, http.StatusOK, events)
+}
+
+func initSpam(e *exporter) {
+	if *spamInterval <= 0 {
+		return
+	}
+	if *spamAlpha <= 0 || *spamAlpha > 1 {
+		log.Fatalf("Invalid --spam.alpha %v, must be in (0, 1].", *spamAlpha)
+	}
+	e.spam = newSpamDetector(e, *spamInterval, *spamAlpha, *spamSensitivity, *spamMinRate, *spamMaxSources)
+	go e.spam.run()
+}
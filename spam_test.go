@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+func TestSpamDetector(t *testing.T) {
+
+	e := newExporter("")
+	sd := newSpamDetector(e, 10*time.Second, 0.2, 3, 1, 100)
+	now := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// Steady traffic of 2 tx/s over ten tags
+	steady := func() {
+		for i := 0; i < 20; i++ {
+			sd.seen(&transaction{Tag: fmt.Sprintf("TAG%d", i%10), Address: fmt.Sprintf("ADDR%d", i)})
+		}
+	}
+	for i := 0; i < 10; i++ {
+		steady()
+		if events := sd.tick(now); len(events) != 0 {
+			t.Fatalf("Test steady: Expected no events, got %v", events)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	if sd.score != 1 {
+		t.Errorf("Test steady: Expected a spam score of 1, got %v", sd.score)
+	}
+
+	// A flood of 10 tx/s from one tag and address, value transactions do not count
+	steady()
+	for i := 0; i < 100; i++ {
+		sd.seen(&transaction{Tag: "SPAM", Address: "SPAMMER"})
+		sd.seen(&transaction{Tag: "SPAM", Address: "SPAMMER", Value: 1})
+	}
+	events := sd.tick(now)
+	if len(events) != 2 {
+		t.Fatalf("Test flood: Expected events for the tag and address, got %v", events)
+	}
+	if sd.score != 6 {
+		t.Errorf("Test flood: Expected a spam score of 6, got %v", sd.score)
+	}
+	if c := testutil.ToFloat64(e.iotaZmqSpamEvents.WithLabelValues("tag")); c != 1 {
+		t.Errorf("Test flood: Expected 1 tag event, got %v", c)
+	}
+	e.spam = sd
+	if active := sd.active(); len(active) != 2 || active[0].Rate != 10 {
+		t.Errorf("Test flood: Expected 2 active sources at 10 tx/s, got %v", active)
+	}
+
+	// The flood continues without new events, then stops
+	for i := 0; i < 100; i++ {
+		sd.seen(&transaction{Tag: "SPAM", Address: "SPAMMER"})
+	}
+	if events := sd.tick(now.Add(10 * time.Second)); len(events) != 0 {
+		t.Errorf("Test ongoing flood: Expected no new events, got %v", events)
+	}
+	sd.tick(now.Add(20 * time.Second))
+	if active := sd.active(); len(active) != 0 {
+		t.Errorf("Test end of flood: Expected no active sources, got %v", active)
+	}
+}
+
+func TestCollectSpamGolden(t *testing.T) {
+
+	e := newExporter("")
+	e.spam = newSpamDetector(e, 10*time.Second, 0.2, 3, 1, 100)
+	now := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// Steady traffic of 2 tx/s, then a flood of 10 tx/s from one tag and address
+	for i := 0; i <= 10; i++ {
+		for j := 0; j < 20; j++ {
+			e.spam.seen(&transaction{Tag: fmt.Sprintf("TAG%d", j%10), Address: fmt.Sprintf("ADDR%d", j)})
+		}
+		if i == 10 {
+			for j := 0; j < 100; j++ {
+				e.spam.seen(&transaction{Tag: "SPAM", Address: "SPAMMER"})
+			}
+		}
+		e.spam.tick(now)
+		now = now.Add(10 * time.Second)
+	}
+	scrapeSpam(e)
+
+	checkGolden(t, "spam", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectSpam(e, ch)
+	})))
+}
@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// minRetention is the shortest retention the size guard of a store will
+// tighten to.
+const minRetention = time.Hour
+
+var errTxNotFound = errors.New("transaction not found")
+var errMetaNotFound = errors.New("metadata not found")
+
+// storeEntry is a transaction record together with its hash.
+type storeEntry struct {
+	hash string
+	rec  txRecord
+}
+
+// storeRetention is how long a txStore keeps the records of transactions
+// that were seen but not confirmed yet, and of confirmed ones.
+type storeRetention struct {
+	seen      time.Duration
+	confirmed time.Duration
+}
+
+// tightened returns the retention halved n times, but not below minRetention.
+func (r storeRetention) tightened(n uint) storeRetention {
+	shorten := func(d time.Duration) time.Duration {
+		for i := uint(0); i < n && d/2 >= minRetention; i++ {
+			d /= 2
+		}
+		return d
+	}
+	return storeRetention{seen: shorten(r.seen), confirmed: shorten(r.confirmed)}
+}
+
+// expired tells whether a record is older than the retention at now, a
+// txRecord time.
+func (r storeRetention) expired(rec *txRecord, now int64) bool {
+	age, ttl := recordDuration(rec.TxIn, now), r.seen
+	if rec.TxConfirmed != 0 {
+		age, ttl = recordDuration(rec.TxConfirmed, now), r.confirmed
+	}
+	return age > ttl.Seconds()
+}
+
+// storeStats describes the state of a txStore for the database metrics.
+type storeStats struct {
+	lsmSize    int64
+	vlogSize   int64
+	keys       int64
+	gcRuns     map[string]float64
+	gcDuration float64
+	retention  storeRetention
+}
+
+// txStore keeps the records of transactions seen on the ZMQ stream, so their
+// confirmation time can be measured once the sn message for them arrives.
+type txStore interface {
+	// Put stores the record of a newly seen transaction.
+	Put(hash string, rec *txRecord) error
+	// PutBatch stores the records of several newly seen transactions at once.
+	PutBatch(entries []storeEntry) error
+	// Get returns the record of a stored transaction, or errTxNotFound.
+	Get(hash string) (*txRecord, error)
+	// Confirm sets the confirmation time of a stored transaction and returns
+	// the updated record, or errTxNotFound when the hash is unknown.
+	Confirm(hash string, confirmed int64) (*txRecord, error)
+	// GetMeta and PutMeta keep exporter state, like the ZMQ checkpoint, next
+	// to the transaction records. Metadata does not expire.
+	GetMeta(key string) ([]byte, error)
+	PutMeta(key string, val []byte) error
+	// ForEach calls fn for every transaction record until fn returns false.
+	// fn must not call the store.
+	ForEach(fn func(hash string, rec *txRecord) bool) error
+	Stats() storeStats
+	Close() error
+}
+
+func openTxStore(backend string) (txStore, error) {
+	retention := storeRetention{seen: *databaseSeenTTL, confirmed: *databaseConfirmedTTL}
+
+	switch backend {
+	case "badger":
+		store, err := openBadgerStore(*databasePath, retention)
+		if err != nil {
+			return nil, err
+		}
+		go store.maintain(*databaseGCInterval, *databaseGCDiscardRatio, int64(*databaseMaxSize))
+		return store, nil
+	case "memory":
+		return newMemoryStore(*memoryMaxRecords, retention), nil
+	}
+	return nil, fmt.Errorf("unknown database backend %q", backend)
+}
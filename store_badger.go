@@ -0,0 +1,399 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/dgraph-io/badger"
+	"github.com/prometheus/common/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// badgerMetaPrefix starts the keys of metadata, which cannot clash with
+// transaction hashes as those only hold trytes.
+var badgerMetaPrefix = []byte("meta/")
+
+// badgerStore keeps transaction records on disk in a Badger database.
+type badgerStore struct {
+	db   *badger.DB
+	path string
+
+	// The fields below are guarded by the mutex. tighten counts how often
+	// the retention was halved because the database outgrew its budget.
+	sync.Mutex
+	retention storeRetention
+	tighten   uint
+	stats     storeStats
+}
+
+func openBadgerStore(path string, retention storeRetention) (*badgerStore, error) {
+	opts := badger.DefaultOptions
+	opts.Dir = path
+	opts.ValueDir = path
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &badgerStore{
+		db:        db,
+		path:      path,
+		retention: retention,
+		stats:     storeStats{gcRuns: map[string]float64{}},
+	}
+	s.stats.lsmSize, s.stats.vlogSize = s.diskSize()
+	return s, nil
+}
+
+// effectiveRetention returns the retention after tightening by the size guard.
+func (s *badgerStore) effectiveRetention() storeRetention {
+	s.Lock()
+	defer s.Unlock()
+	return s.retention.tightened(s.tighten)
+}
+
+func (s *badgerStore) Put(hash string, rec *txRecord) error {
+	ttl := s.effectiveRetention().seen
+	return s.db.Update(func(txn *badger.Txn) error {
+		val, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		log.Debugf("BadgerDB write: key(%s) value(%s)", hash, val)
+		return txn.SetWithTTL([]byte(hash), val, ttl)
+	})
+}
+
+// PutBatch writes all records in as few transactions as possible, committing
+// and starting a new one whenever Badger reports the transaction is too big.
+func (s *badgerStore) PutBatch(entries []storeEntry) error {
+	ttl := s.effectiveRetention().seen
+	txn := s.db.NewTransaction(true)
+	defer func() { txn.Discard() }()
+
+	for i := range entries {
+		val, err := json.Marshal(&entries[i].rec)
+		if err != nil {
+			return err
+		}
+		key := []byte(entries[i].hash)
+
+		err = txn.SetWithTTL(key, val, ttl)
+		if err == badger.ErrTxnTooBig {
+			if err := txn.Commit(nil); err != nil {
+				return err
+			}
+			txn = s.db.NewTransaction(true)
+			err = txn.SetWithTTL(key, val, ttl)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	log.Debugf("BadgerDB batch write: %d records", len(entries))
+
+	return txn.Commit(nil)
+}
+
+func (s *badgerStore) Get(hash string) (*txRecord, error) {
+	rec := &txRecord{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(hash))
+		if err == badger.ErrKeyNotFound {
+			return errTxNotFound
+		} else if err != nil {
+			return err
+		}
+
+		v, err := item.Value()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(v, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *badgerStore) Confirm(hash string, confirmed int64) (*txRecord, error) {
+	ttl := s.effectiveRetention().confirmed
+	rec := &txRecord{}
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(hash))
+		if err == badger.ErrKeyNotFound {
+			return errTxNotFound
+		} else if err != nil {
+			return err
+		}
+
+		v, err := item.Value()
+		if err != nil {
+			return err
+		}
+		log.Debugf("BadgerDB get: key(%s) value(%s)", hash, v)
+		if err := json.Unmarshal(v, rec); err != nil {
+			return err
+		}
+
+		rec.TxConfirmed = confirmed
+		v, err = json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return txn.SetWithTTL([]byte(hash), v, ttl)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *badgerStore) ForEach(fn func(hash string, rec *txRecord) bool) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if bytes.HasPrefix(item.Key(), badgerMetaPrefix) {
+				continue
+			}
+
+			v, err := item.Value()
+			if err != nil {
+				return err
+			}
+			rec := txRecord{}
+			if json.Unmarshal(v, &rec) != nil {
+				continue
+			}
+			if !fn(string(item.Key()), &rec) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (s *badgerStore) GetMeta(key string) ([]byte, error) {
+	var val []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(append(badgerMetaPrefix, key...))
+		if err == badger.ErrKeyNotFound {
+			return errMetaNotFound
+		} else if err != nil {
+			return err
+		}
+		v, err := item.Value()
+		val = append([]byte(nil), v...)
+		return err
+	})
+	return val, err
+}
+
+func (s *badgerStore) PutMeta(key string, val []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(append(badgerMetaPrefix, key...), val)
+	})
+}
+
+func (s *badgerStore) Stats() storeStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := s.stats
+	stats.gcRuns = make(map[string]float64, len(s.stats.gcRuns))
+	for result, runs := range s.stats.gcRuns {
+		stats.gcRuns[result] = runs
+	}
+	stats.retention = s.retention.tightened(s.tighten)
+	return stats
+}
+
+func (s *badgerStore) Close() error {
+	return s.db.Close()
+}
+
+// diskSize returns the size of the LSM tree and value log files on disk.
+func (s *badgerStore) diskSize() (lsm int64, vlog int64) {
+	filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, ".sst") {
+			lsm += info.Size()
+		} else if strings.HasSuffix(path, ".vlog") {
+			vlog += info.Size()
+		}
+		return nil
+	})
+	return lsm, vlog
+}
+
+// maintain runs the database maintenance every interval. A maxSize of zero
+// disables the size guard.
+func (s *badgerStore) maintain(interval time.Duration, discardRatio float64, maxSize int64) {
+	for {
+		time.Sleep(interval)
+		s.runMaintenance(discardRatio, maxSize)
+	}
+}
+
+// runMaintenance enforces the disk budget, garbage collects the value log
+// and refreshes the database statistics. When the database is larger than
+// maxSize the retention is halved and records older than the tightened
+// retention are deleted; once it is back under three quarters of the budget
+// the retention is relaxed again one step at a time.
+func (s *badgerStore) runMaintenance(discardRatio float64, maxSize int64) {
+
+	lsm, vlog := s.diskSize()
+	retention := s.retention
+	s.Lock()
+	if maxSize > 0 && lsm+vlog > maxSize && retention.tightened(s.tighten) != retention.tightened(s.tighten+1) {
+		s.tighten++
+		log.Infof("BadgerDB size %d exceeds %d bytes, tightening retention to %v.", lsm+vlog, maxSize, retention.tightened(s.tighten))
+	} else if s.tighten > 0 && (maxSize <= 0 || lsm+vlog < maxSize/4*3) {
+		s.tighten--
+	}
+	tightened := s.tighten > 0
+	retention = retention.tightened(s.tighten)
+	s.Unlock()
+
+	keys, err := s.expire(retention, tightened)
+	if err != nil {
+		log.Infof("BadgerDB error %v.", err)
+	}
+
+	start := time.Now()
+	result := s.gc(discardRatio)
+	duration := time.Since(start).Seconds()
+	log.Infof("BadgerDB purge: %s in %.1fs.", result, duration)
+
+	lsm, vlog = s.diskSize()
+
+	s.Lock()
+	s.stats.lsmSize = lsm
+	s.stats.vlogSize = vlog
+	s.stats.keys = keys
+	s.stats.gcRuns[result]++
+	s.stats.gcDuration = duration
+	s.Unlock()
+}
+
+// expire counts the records in the database. When prune is set, records
+// older than the given retention are deleted instead of waiting for their
+// TTL, and are not counted.
+func (s *badgerStore) expire(retention storeRetention, prune bool) (int64, error) {
+
+	var keys int64
+	var expired [][]byte
+	now := recordTime(time.Now())
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = prune
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if bytes.HasPrefix(item.Key(), badgerMetaPrefix) {
+				continue
+			}
+
+			keys++
+			if !prune {
+				continue
+			}
+
+			v, err := item.Value()
+			if err != nil {
+				return err
+			}
+			rec := txRecord{}
+			if json.Unmarshal(v, &rec) != nil {
+				continue
+			}
+
+			if retention.expired(&rec, now) {
+				expired = append(expired, append([]byte(nil), item.Key()...))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return keys, err
+	}
+
+	txn := s.db.NewTransaction(true)
+	defer func() { txn.Discard() }()
+	for _, key := range expired {
+		err := txn.Delete(key)
+		if err == badger.ErrTxnTooBig {
+			if err := txn.Commit(nil); err != nil {
+				return keys, err
+			}
+			txn = s.db.NewTransaction(true)
+			err = txn.Delete(key)
+		}
+		if err != nil {
+			return keys, err
+		}
+	}
+	if len(expired) > 0 {
+		log.Infof("BadgerDB deleted %d records beyond the retention.", len(expired))
+	}
+
+	return keys - int64(len(expired)), txn.Commit(nil)
+}
+
+// gc removes old versions and rewrites value log files until Badger finds
+// nothing left to clean up. It returns ok, nothing or error.
+func (s *badgerStore) gc(discardRatio float64) string {
+
+	if err := s.db.PurgeOlderVersions(); err != nil {
+		log.Infof("BadgerDB error %v.", err)
+		return "error"
+	}
+
+	result := "nothing"
+	for {
+		err := s.db.RunValueLogGC(discardRatio)
+		if err == badger.ErrNoRewrite {
+			return result
+		} else if err != nil {
+			log.Infof("BadgerDB error %v.", err)
+			return "error"
+		}
+		result = "ok"
+	}
+}
@@ -0,0 +1,188 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps transaction records in memory. It holds at most max
+// records, evicting the least recently used one when full, and drops records
+// once their TTL has passed. Nothing survives a restart.
+type memoryStore struct {
+	sync.Mutex
+	max       int
+	retention storeRetention
+	lru       *list.List
+	records   map[string]*list.Element
+	meta      map[string][]byte
+	now       func() time.Time
+}
+
+type memoryRecord struct {
+	hash    string
+	rec     txRecord
+	expires time.Time
+}
+
+func newMemoryStore(max int, retention storeRetention) *memoryStore {
+	return &memoryStore{
+		max:       max,
+		retention: retention,
+		lru:       list.New(),
+		records:   make(map[string]*list.Element),
+		meta:      make(map[string][]byte),
+		now:       time.Now,
+	}
+}
+
+func (s *memoryStore) Put(hash string, rec *txRecord) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.put(hash, rec)
+	return nil
+}
+
+func (s *memoryStore) PutBatch(entries []storeEntry) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for i := range entries {
+		s.put(entries[i].hash, &entries[i].rec)
+	}
+	return nil
+}
+
+func (s *memoryStore) put(hash string, rec *txRecord) {
+	expires := s.now().Add(s.retention.seen)
+	if el, ok := s.records[hash]; ok {
+		el.Value = &memoryRecord{hash: hash, rec: *rec, expires: expires}
+		s.lru.MoveToFront(el)
+		return
+	}
+
+	s.records[hash] = s.lru.PushFront(&memoryRecord{hash: hash, rec: *rec, expires: expires})
+	for s.max > 0 && s.lru.Len() > s.max {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *memoryStore) Get(hash string) (*txRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	el := s.lookup(hash)
+	if el == nil {
+		return nil, errTxNotFound
+	}
+	rec := el.Value.(*memoryRecord).rec
+	return &rec, nil
+}
+
+func (s *memoryStore) Confirm(hash string, confirmed int64) (*txRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	el := s.lookup(hash)
+	if el == nil {
+		return nil, errTxNotFound
+	}
+
+	mr := el.Value.(*memoryRecord)
+	mr.rec.TxConfirmed = confirmed
+	mr.expires = s.now().Add(s.retention.confirmed)
+	s.lru.MoveToFront(el)
+
+	rec := mr.rec
+	return &rec, nil
+}
+
+func (s *memoryStore) ForEach(fn func(hash string, rec *txRecord) bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		r := el.Value.(*memoryRecord)
+		if !now.Before(r.expires) {
+			continue
+		}
+		rec := r.rec
+		if !fn(r.hash, &rec) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) GetMeta(key string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	val, ok := s.meta[key]
+	if !ok {
+		return nil, errMetaNotFound
+	}
+	return val, nil
+}
+
+func (s *memoryStore) PutMeta(key string, val []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.meta[key] = append([]byte(nil), val...)
+	return nil
+}
+
+func (s *memoryStore) Stats() storeStats {
+	s.Lock()
+	defer s.Unlock()
+	return storeStats{keys: int64(s.lru.Len()), retention: s.retention}
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// lookup returns the list element of a record that has not expired yet.
+func (s *memoryStore) lookup(hash string) *list.Element {
+	el, ok := s.records[hash]
+	if !ok {
+		return nil
+	}
+	if !s.now().Before(el.Value.(*memoryRecord).expires) {
+		s.remove(el)
+		return nil
+	}
+	return el
+}
+
+func (s *memoryStore) remove(el *list.Element) {
+	delete(s.records, el.Value.(*memoryRecord).hash)
+	s.lru.Remove(el)
+}
@@ -0,0 +1,185 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+var testRetention = storeRetention{seen: 15 * 24 * time.Hour, confirmed: 24 * time.Hour}
+
+// testTxStore is the conformance suite every txStore backend has to pass.
+func testTxStore(t *testing.T, store txStore) {
+
+	hash := "TXHASH9999999999999999999999999999999999999999999999999999999999999999999999999"
+	rec := txRecord{
+		Timestamp:   1520000000,
+		TxIn:        20180304120000,
+		TxAddress:   "ADDRESS",
+		TxValue:     42,
+		MilestoneIn: 400000,
+	}
+
+	if _, err := store.Get(hash); err != errTxNotFound {
+		t.Errorf("Expected errTxNotFound getting an unknown tx, got %v", err)
+	}
+	if _, err := store.Confirm(hash, 20180304120500); err != errTxNotFound {
+		t.Errorf("Expected errTxNotFound confirming an unknown tx, got %v", err)
+	}
+
+	if err := store.Put(hash, &rec); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if got, err := store.Get(hash); err != nil || *got != rec {
+		t.Errorf("Expected record %v, got %v %v", rec, got, err)
+	}
+
+	got, err := store.Confirm(hash, 20180304120500)
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	rec.TxConfirmed = 20180304120500
+	if *got != rec {
+		t.Errorf("Expected confirmed record %v, got %v", rec, *got)
+	}
+
+	// Seeing the tx again replaces the record.
+	rec.TxConfirmed = 0
+	rec.TxIn = 20180304121000
+	if err := store.Put(hash, &rec); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, err = store.Confirm(hash, 20180304121500)
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if got.TxIn != 20180304121000 || got.TxConfirmed != 20180304121500 {
+		t.Errorf("Expected the replaced record, got %v", *got)
+	}
+
+	// Metadata is not listed with the records.
+	if err := store.PutMeta("checkpoint", []byte("{}")); err != nil {
+		t.Fatalf("PutMeta failed: %v", err)
+	}
+	store.Put("OTHER", &rec) // nolint: errcheck
+	hashes := map[string]bool{}
+	err = store.ForEach(func(hash string, rec *txRecord) bool {
+		hashes[hash] = true
+		return true
+	})
+	if err != nil || len(hashes) != 2 || !hashes[hash] || !hashes["OTHER"] {
+		t.Errorf("Expected ForEach to list %v and OTHER, got %v %v", hash, hashes, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := newMemoryStore(10, testRetention)
+	defer store.Close()
+	testTxStore(t, store)
+}
+
+func TestBadgerStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iotabadgerdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := openBadgerStore(dir, testRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testTxStore(t, store)
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+
+	now := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+	store := newMemoryStore(2, testRetention)
+	store.now = func() time.Time { return now }
+
+	store.Put("A", &txRecord{})
+	store.Put("B", &txRecord{})
+	store.Confirm("A", 1) // A is now the most recently used record
+	store.Put("C", &txRecord{})
+
+	if _, err := store.Confirm("B", 1); err != errTxNotFound {
+		t.Errorf("Expected B to be evicted, got %v", err)
+	}
+
+	// A is confirmed and expires sooner than the unconfirmed C.
+	now = now.Add(testRetention.confirmed)
+	if _, err := store.Confirm("A", 1); err != errTxNotFound {
+		t.Errorf("Expected A to be expired, got %v", err)
+	}
+	if _, err := store.Confirm("C", 1); err != nil {
+		t.Errorf("Expected C to be kept, got %v", err)
+	}
+}
+
+func TestStoreRetentionTightened(t *testing.T) {
+
+	r := storeRetention{seen: 8 * time.Hour, confirmed: 2 * time.Hour}
+
+	if got := r.tightened(2); got.seen != 2*time.Hour || got.confirmed != minRetention {
+		t.Errorf("Expected 2h/1h after tightening twice, got %v/%v", got.seen, got.confirmed)
+	}
+	if got := r.tightened(10); got.seen != minRetention || got.confirmed != minRetention {
+		t.Errorf("Expected retention to stop at %v, got %v/%v", minRetention, got.seen, got.confirmed)
+	}
+}
+
+func TestBadgerStoreExpire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iotabadgerdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := openBadgerStore(dir, testRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Put("OLD", &txRecord{TxIn: recordTime(time.Now().Add(-3 * time.Hour))})
+	store.Put("NEW", &txRecord{TxIn: recordTime(time.Now())})
+
+	keys, err := store.expire(storeRetention{seen: 2 * time.Hour, confirmed: time.Hour}, true)
+	if err != nil || keys != 1 {
+		t.Errorf("Expected 1 record left, got %v (%v)", keys, err)
+	}
+	if _, err := store.Confirm("OLD", 1); err != errTxNotFound {
+		t.Errorf("Expected the old record to be deleted, got %v", err)
+	}
+	if _, err := store.Confirm("NEW", 1); err != nil {
+		t.Errorf("Expected the new record to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"container/list"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+// tangleNode is a transaction in the approval graph.
+type tangleNode struct {
+	hash     string
+	seen     time.Time
+	approved bool
+	element  *list.Element
+}
+
+// approvalGraph keeps the transactions seen during the last window and
+// whether they were approved through the trunk or branch of a later
+// transaction. Transactions leaving the window unapproved are counted as
+// orphans; at most max transactions are kept.
+type approvalGraph struct {
+	sync.Mutex
+	max    int
+	window time.Duration
+	nodes  map[string]*tangleNode
+	order  *list.List
+	tips   int
+
+	evaluated      float64
+	orphans        float64
+	lastEvaluated  float64
+	lastOrphans    float64
+	approvalAge    prometheus.Histogram
+	references     *prometheus.CounterVec
+	evaluatedTotal prometheus.Counter
+	orphansTotal   prometheus.Counter
+}
+
+func newApprovalGraph(e *exporter, max int, window time.Duration) *approvalGraph {
+	return &approvalGraph{
+		max:            max,
+		window:         window,
+		nodes:          make(map[string]*tangleNode),
+		order:          list.New(),
+		approvalAge:    e.iotaTangleApprovalAge,
+		references:     e.iotaTangleReferences,
+		evaluatedTotal: e.iotaTangleEvaluated,
+		orphansTotal:   e.iotaTangleOrphans,
+	}
+}
+
+func metricsTangle(e *exporter) {
+
+	e.iotaTangleTips = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "tangle",
+			//Name: "tangle_tips",
+			Name: "iota_tangle_tips",
+			Help: "Transactions seen by zeroMQ during --tangle.window that are not approved yet.",
+		})
+
+	e.iotaTangleApprovalAge = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "tangle",
+			//Name: "tangle_approval_age_seconds",
+			Name:    "iota_tangle_approval_age_seconds",
+			Help:    "Age of the transactions referenced by trunk and branch, for references within --tangle.window.",
+			Buckets: []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+		})
+
+	e.iotaTangleReferences = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "tangle",
+			//Name: "tangle_references_total",
+			Name: "iota_tangle_references_total",
+			Help: "Trunk and branch references to transactions seen within --tangle.window (recent) or not (old, e.g. lazy tips).",
+		},
+		[]string{"target"},
+	)
+
+	e.iotaTangleEvaluated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "tangle",
+			//Name: "tangle_evaluated_total",
+			Name: "iota_tangle_evaluated_total",
+			Help: "Transactions that left --tangle.window, approved or not.",
+		})
+
+	e.iotaTangleOrphans = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "tangle",
+			//Name: "tangle_orphans_total",
+			Name: "iota_tangle_orphans_total",
+			Help: "Transactions that were not approved within --tangle.window.",
+		})
+
+	e.iotaTangleOrphanRate = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "tangle",
+			//Name: "tangle_orphan_rate",
+			Name: "iota_tangle_orphan_rate",
+			Help: "Fraction of the transactions that left --tangle.window since the previous scrape without being approved.",
+		})
+}
+
+func describeTangle(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaTangleTips.Desc()
+	ch <- e.iotaTangleApprovalAge.Desc()
+	e.iotaTangleReferences.Describe(ch)
+	ch <- e.iotaTangleEvaluated.Desc()
+	ch <- e.iotaTangleOrphans.Desc()
+	ch <- e.iotaTangleOrphanRate.Desc()
+}
+
+func collectTangle(e *exporter, ch chan<- prometheus.Metric) {
+	ch <- e.iotaTangleTips
+	ch <- e.iotaTangleApprovalAge
+	e.iotaTangleReferences.Collect(ch)
+	ch <- e.iotaTangleEvaluated
+	ch <- e.iotaTangleOrphans
+	ch <- e.iotaTangleOrphanRate
+}
+
+func scrapeTangle(e *exporter) {
+	if e.tangle == nil {
+		return
+	}
+
+	tips, rate, ok := e.tangle.scrape(time.Now())
+	e.iotaTangleTips.Set(float64(tips))
+	if ok {
+		e.iotaTangleOrphanRate.Set(rate)
+	}
+}
+
+// seen adds a transaction to the graph and marks the transactions it
+// references as approved.
+func (g *approvalGraph) seen(tx *transaction, now time.Time) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.expire(now)
+
+	refs := []string{tx.Trunk}
+	if tx.Branch != tx.Trunk {
+		refs = append(refs, tx.Branch)
+	}
+	for _, ref := range refs {
+		node, ok := g.nodes[ref]
+		if !ok {
+			g.references.WithLabelValues("old").Inc()
+			continue
+		}
+		g.references.WithLabelValues("recent").Inc()
+		g.approvalAge.Observe(now.Sub(node.seen).Seconds())
+		if !node.approved {
+			node.approved = true
+			g.tips--
+		}
+	}
+
+	if _, ok := g.nodes[tx.Hash]; ok {
+		return
+	}
+	if g.max > 0 && len(g.nodes) >= g.max {
+		g.remove(g.order.Front().Value.(*tangleNode))
+	}
+	node := &tangleNode{hash: tx.Hash, seen: now}
+	node.element = g.order.PushBack(node)
+	g.nodes[tx.Hash] = node
+	g.tips++
+}
+
+// expire evaluates the transactions that left the window.
+func (g *approvalGraph) expire(now time.Time) {
+	for el := g.order.Front(); el != nil; el = g.order.Front() {
+		node := el.Value.(*tangleNode)
+		if now.Sub(node.seen) < g.window {
+			break
+		}
+		g.evaluated++
+		g.evaluatedTotal.Inc()
+		if !node.approved {
+			g.orphans++
+			g.orphansTotal.Inc()
+		}
+		g.remove(node)
+	}
+}
+
+// remove drops a transaction from the graph without evaluating it.
+func (g *approvalGraph) remove(node *tangleNode) {
+	if !node.approved {
+		g.tips--
+	}
+	g.order.Remove(node.element)
+	delete(g.nodes, node.hash)
+}
+
+// scrape returns the number of tips and the orphan rate since the previous
+// scrape, if any transaction was evaluated.
+func (g *approvalGraph) scrape(now time.Time) (int, float64, bool) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.expire(now)
+
+	evaluated, orphans := g.evaluated-g.lastEvaluated, g.orphans-g.lastOrphans
+	g.lastEvaluated, g.lastOrphans = g.evaluated, g.orphans
+	if evaluated == 0 {
+		return g.tips, 0, false
+	}
+	return g.tips, orphans / evaluated, true
+}
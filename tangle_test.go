@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+func TestApprovalGraph(t *testing.T) {
+
+	e := newExporter("")
+	g := newApprovalGraph(e, 100, 10*time.Minute)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// A approves nothing known, B and C approve A, D approves B twice
+	g.seen(&transaction{Hash: "A", Trunk: "OLD1", Branch: "OLD2"}, start)
+	g.seen(&transaction{Hash: "B", Trunk: "A", Branch: "OLD1"}, start.Add(time.Second))
+	g.seen(&transaction{Hash: "C", Trunk: "A", Branch: "A"}, start.Add(5*time.Second))
+	g.seen(&transaction{Hash: "D", Trunk: "B", Branch: "B"}, start.Add(time.Minute))
+
+	tips, _, ok := g.scrape(start.Add(2 * time.Minute))
+	if tips != 2 || ok {
+		t.Errorf("Test tips: Expected 2 tips (C and D) and no orphan rate, got %v %v", tips, ok)
+	}
+	if c := testutil.ToFloat64(e.iotaTangleReferences.WithLabelValues("old")); c != 3 {
+		t.Errorf("Test references: Expected 3 old references, got %v", c)
+	}
+	if c := testutil.ToFloat64(e.iotaTangleReferences.WithLabelValues("recent")); c != 3 {
+		t.Errorf("Test references: Expected 3 recent references, got %v", c)
+	}
+
+	// All transactions leave the window, C and D were never approved
+	tips, rate, ok := g.scrape(start.Add(20 * time.Minute))
+	if tips != 0 || !ok || rate != 0.5 {
+		t.Errorf("Test orphans: Expected no tips and an orphan rate of 0.5, got %v %v %v", tips, rate, ok)
+	}
+	if c := testutil.ToFloat64(e.iotaTangleOrphans); c != 2 {
+		t.Errorf("Test orphans: Expected 2 orphans, got %v", c)
+	}
+
+	// The graph is bounded
+	g.max = 2
+	for _, hash := range []string{"E", "F", "G"} {
+		g.seen(&transaction{Hash: hash}, start.Add(21*time.Minute))
+	}
+	if len(g.nodes) != 2 || g.tips != 2 {
+		t.Errorf("Test max: Expected 2 transactions and 2 tips, got %v and %v", len(g.nodes), g.tips)
+	}
+}
+
+func TestCollectTangleGolden(t *testing.T) {
+
+	e := newExporter("")
+	e.tangle = newApprovalGraph(e, 100, 10*time.Minute)
+	start := time.Now().Add(-20 * time.Minute)
+
+	// A to D leave the window, C and D unapproved; E is a tip
+	e.tangle.seen(&transaction{Hash: "A", Trunk: "OLD1", Branch: "OLD2"}, start)
+	e.tangle.seen(&transaction{Hash: "B", Trunk: "A", Branch: "OLD1"}, start.Add(time.Second))
+	e.tangle.seen(&transaction{Hash: "C", Trunk: "A", Branch: "A"}, start.Add(5*time.Second))
+	e.tangle.seen(&transaction{Hash: "D", Trunk: "B", Branch: "B"}, start.Add(time.Minute))
+	e.tangle.seen(&transaction{Hash: "E", Trunk: "OLD3", Branch: "OLD3"}, start.Add(19*time.Minute))
+	scrapeTangle(e)
+
+	checkGolden(t, "tangle", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectTangle(e, ch)
+	})))
+}
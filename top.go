@@ -0,0 +1,340 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// topDimensions are the keys counted by the heavy hitters tracker. A sender
+// is the address of an input (negative value) or of the tail of a zero value
+// bundle.
+var topDimensions = []string{"tag", "address", "sender"}
+
+const (
+	topSketchDepth = 4
+	topSketchWidth = 2048
+	topSlices      = 10
+)
+
+// countMinSketch estimates the counts of a stream of keys in fixed memory.
+// Estimates are never lower than the real count.
+type countMinSketch struct {
+	counts [topSketchDepth][topSketchWidth]uint32
+}
+
+func (cms *countMinSketch) index(key string, row int) int {
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], uint64(row)*0x9E3779B97F4A7C15)
+	h.Write(seed[:])     // nolint: errcheck
+	h.Write([]byte(key)) // nolint: errcheck
+	return int(h.Sum64() % topSketchWidth)
+}
+
+func (cms *countMinSketch) add(key string) uint32 {
+	min := ^uint32(0)
+	for row := 0; row < topSketchDepth; row++ {
+		i := cms.index(key, row)
+		cms.counts[row][i]++
+		if cms.counts[row][i] < min {
+			min = cms.counts[row][i]
+		}
+	}
+	return min
+}
+
+func (cms *countMinSketch) estimate(key string) uint32 {
+	min := ^uint32(0)
+	for row := 0; row < topSketchDepth; row++ {
+		if c := cms.counts[row][cms.index(key, row)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// topEntry is a candidate heavy hitter.
+type topEntry struct {
+	key   string
+	count uint32
+	index int
+}
+
+// topHeap is a min-heap of candidates, so the smallest is replaced first.
+type topHeap []*topEntry
+
+func (h topHeap) Len() int            { return len(h) }
+func (h topHeap) Less(i, j int) bool  { return h[i].count < h[j].count }
+func (h topHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
+func (h *topHeap) Push(x interface{}) { e := x.(*topEntry); e.index = len(*h); *h = append(*h, e) }
+func (h *topHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// topSlice counts the keys of a part of the window.
+type topSlice struct {
+	start      time.Time
+	sketch     countMinSketch
+	candidates map[string]*topEntry
+	heap       topHeap
+}
+
+// heavyHitters keeps the most frequent keys over a sliding window. The
+// window is split in slices that each have a sketch and a bounded set of
+// candidates; the oldest slice is dropped as the window slides.
+type heavyHitters struct {
+	window    time.Duration
+	slice     time.Duration
+	capacity  int
+	slices    []*topSlice
+	lastSlice time.Time
+}
+
+func newHeavyHitters(window time.Duration, capacity int) *heavyHitters {
+	return &heavyHitters{
+		window:   window,
+		slice:    window / topSlices,
+		capacity: capacity,
+	}
+}
+
+// rotate drops the slices that left the window and starts a new slice when
+// the current one is full.
+func (hh *heavyHitters) rotate(now time.Time) {
+	for len(hh.slices) > 0 && now.Sub(hh.slices[0].start) >= hh.window {
+		hh.slices = hh.slices[1:]
+	}
+	if len(hh.slices) == 0 || now.Sub(hh.slices[len(hh.slices)-1].start) >= hh.slice {
+		hh.slices = append(hh.slices, &topSlice{start: now.Truncate(hh.slice), candidates: make(map[string]*topEntry)})
+	}
+}
+
+func (hh *heavyHitters) add(key string, now time.Time) {
+	hh.rotate(now)
+
+	s := hh.slices[len(hh.slices)-1]
+	count := s.sketch.add(key)
+	if e, ok := s.candidates[key]; ok {
+		e.count = count
+		heap.Fix(&s.heap, e.index)
+	} else if len(s.heap) < hh.capacity {
+		e := &topEntry{key: key, count: count}
+		heap.Push(&s.heap, e)
+		s.candidates[key] = e
+	} else if s.heap[0].count < count {
+		e := s.heap[0]
+		delete(s.candidates, e.key)
+		e.key, e.count = key, count
+		s.candidates[key] = e
+		heap.Fix(&s.heap, 0)
+	}
+}
+
+// top returns the n most frequent keys with their estimated counts.
+func (hh *heavyHitters) top(n int, now time.Time) []topEntry {
+	hh.rotate(now)
+
+	keys := make(map[string]bool)
+	for _, s := range hh.slices {
+		for key := range s.candidates {
+			keys[key] = true
+		}
+	}
+
+	list := make([]topEntry, 0, len(keys))
+	for key := range keys {
+		var count uint32
+		for _, s := range hh.slices {
+			count += s.sketch.estimate(key)
+		}
+		list = append(list, topEntry{key: key, count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		return list[i].key < list[j].key
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// topItem is a heavy hitter as returned by the /top endpoint.
+type topItem struct {
+	Rank    int    `json:"rank"`
+	Key     string `json:"key"`
+	Decoded string `json:"decoded,omitempty"`
+	Count   uint32 `json:"count"`
+}
+
+// topTracker keeps the heavy hitters of every dimension for every window.
+type topTracker struct {
+	sync.Mutex
+	n       int
+	windows []string
+	hitters map[string]map[string]*heavyHitters
+}
+
+func newTopTracker(n int, windows []rateWindow) *topTracker {
+	t := &topTracker{n: n, hitters: make(map[string]map[string]*heavyHitters)}
+	for _, window := range windows {
+		t.windows = append(t.windows, window.label)
+		t.hitters[window.label] = make(map[string]*heavyHitters)
+		for _, dimension := range topDimensions {
+			t.hitters[window.label][dimension] = newHeavyHitters(window.duration, 4*n)
+		}
+	}
+	return t
+}
+
+// parseWindows parses a comma separated list of durations. Each window is
+// labeled as given, so "5m" stays "5m" in the metrics and the /top endpoint.
+func parseWindows(def string) ([]rateWindow, error) {
+	var windows []rateWindow
+	for _, s := range strings.Split(def, ",") {
+		label := strings.TrimSpace(s)
+		d, err := time.ParseDuration(label)
+		if err != nil {
+			return nil, err
+		}
+		if d < topSlices*time.Second {
+			return nil, fmt.Errorf("window %v is shorter than %v", d, topSlices*time.Second)
+		}
+		windows = append(windows, rateWindow{label: label, duration: d})
+	}
+	return windows, nil
+}
+
+func (t *topTracker) seen(tx *transaction, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, byDimension := range t.hitters {
+		byDimension["tag"].add(tx.Tag, now)
+		byDimension["address"].add(tx.Address, now)
+		if tx.Value < 0 || (tx.Value == 0 && tx.CurrentIndex == "0") {
+			byDimension["sender"].add(tx.Address, now)
+		}
+	}
+}
+
+// top returns the heavy hitters by window and dimension.
+func (t *topTracker) top(now time.Time) map[string]map[string][]topItem {
+	t.Lock()
+	defer t.Unlock()
+
+	result := make(map[string]map[string][]topItem)
+	for window, byDimension := range t.hitters {
+		result[window] = make(map[string][]topItem)
+		for dimension, hh := range byDimension {
+			items := []topItem{}
+			for i, e := range hh.top(t.n, now) {
+				item := topItem{Rank: i + 1, Key: e.key, Count: e.count}
+				if dimension == "tag" {
+					item.Decoded = trytesToASCII(e.key)
+				}
+				items = append(items, item)
+			}
+			result[window][dimension] = items
+		}
+	}
+	return result
+}
+
+// handleTop returns the heavy hitters, optionally filtered with the window
+// and dimension query parameters.
+func (t *topTracker) handleTop(rw http.ResponseWriter, r *http.Request) {
+	top := t.top(time.Now())
+	if window := r.URL.Query().Get("window"); window != "" {
+		byDimension, ok := top[window]
+		if !ok {
+			writeError(rw, http.StatusNotFound, "unknown window "+window)
+			return
+		}
+		top = map[string]map[string][]topItem{window: byDimension}
+	}
+	if dimension := r.URL.Query().Get("dimension"); dimension != "" {
+		for window := range top {
+			top[window] = map[string][]topItem{dimension: top[window][dimension]}
+		}
+	}
+	writeJSON(rw, http.StatusOK, top)
+}
+
+func initTop(e *exporter) {
+	if *topN <= 0 {
+		return
+	}
+	windows, err := parseWindows(*topWindows)
+	if err != nil {
+		log.Fatalf("Invalid --top.windows: %v", err)
+	}
+	e.top = newTopTracker(*topN, windows)
+}
+
+func metricsTop(e *exporter) {
+
+	e.iotaZmqTop = prometheus.NewDesc(
+		"iota_zmq_top_tx",
+		"Estimated transactions of the most frequent tags, addresses and senders seen by zeroMQ over a window.",
+		[]string{"dimension", "window", "rank", "key"}, nil,
+	)
+}
+
+func describeTop(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaZmqTop
+}
+
+func collectTop(e *exporter, ch chan<- prometheus.Metric) {
+	if e.top == nil {
+		return
+	}
+
+	for window, byDimension := range e.top.top(time.Now()) {
+		for dimension, items := range byDimension {
+			for _, item := range items {
+				ch <- prometheus.MustNewConstMetric(e.iotaZmqTop, prometheus.GaugeValue, float64(item.Count),
+					dimension, window, strconv.Itoa(item.Rank), item.Key)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeavyHitters(t *testing.T) {
+
+	hh := newHeavyHitters(10*time.Minute, 8)
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	// Three heavy keys among many keys that are seen once
+	for i := 0; i < 1000; i++ {
+		now := start.Add(time.Duration(i) * 300 * time.Millisecond)
+		hh.add(fmt.Sprintf("NOISE%d", i), now)
+		if i%2 == 0 {
+			hh.add("SPAM", now)
+		}
+		if i%5 == 0 {
+			hh.add("APP", now)
+		}
+		if i%10 == 0 {
+			hh.add("WALLET", now)
+		}
+	}
+
+	top := hh.top(3, start.Add(5*time.Minute))
+	for i, key := range []string{"SPAM", "APP", "WALLET"} {
+		if i >= len(top) || top[i].key != key {
+			t.Fatalf("Test rank %v: Expected %v, got %v", i+1, key, top)
+		}
+	}
+	if top[0].count < 500 {
+		t.Errorf("Test count: Expected at least 500 for SPAM, got %v", top[0].count)
+	}
+
+	// Everything slides out of the window
+	if top := hh.top(3, start.Add(time.Hour)); len(top) != 0 {
+		t.Errorf("Test window: Expected no keys after the window, got %v", top)
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+
+	windows, err := parseWindows("5m, 1h")
+	if err != nil || len(windows) != 2 || windows[1] != (rateWindow{label: "1h", duration: time.Hour}) {
+		t.Errorf("Expected [5m 1h], got %v %v", windows, err)
+	}
+	if _, err := parseWindows("1s"); err == nil {
+		t.Errorf("Expected an error for a window that is too short")
+	}
+}
+
+func TestHandleTopWindow(t *testing.T) {
+
+	windows, err := parseWindows("5m,1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := newTopTracker(3, windows)
+	top.seen(&transaction{Address: "ADDR", Tag: "TAG", CurrentIndex: "0"}, time.Now())
+
+	rw := httptest.NewRecorder()
+	top.handleTop(rw, httptest.NewRequest("GET", "/top?window=5m&dimension=tag", nil))
+	if rw.Code != 200 {
+		t.Fatalf("Expected status 200 for window 5m, got %v", rw.Code)
+	}
+	var result map[string]map[string][]topItem
+	if err := json.NewDecoder(rw.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if items := result["5m"]["tag"]; len(items) != 1 || items[0].Key != "TAG" {
+		t.Errorf("Expected TAG in the 5m window, got %+v", result)
+	}
+
+	rw = httptest.NewRecorder()
+	top.handleTop(rw, httptest.NewRequest("GET", "/top?window=5m0s", nil))
+	if rw.Code != 404 {
+		t.Errorf("Expected status 404 for window 5m0s, got %v", rw.Code)
+	}
+}
+
+func TestCollectTopGolden(t *testing.T) {
+
+	windows, err := parseWindows("5m,1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newExporter("")
+	e.top = newTopTracker(2, windows)
+
+	now := time.Now()
+	for n, count := range []int{3, 2, 1} {
+		for i := 0; i < count; i++ {
+			e.top.seen(&transaction{Tag: syntheticHash("TAG", n)[:27], Address: syntheticHash("ADDR", n), CurrentIndex: "0"}, now)
+		}
+	}
+
+	checkGolden(t, "top", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectTop(e, ch)
+	})))
+}
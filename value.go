@@ -0,0 +1,128 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// iotaPerMi is the number of iota in a Mi.
+const iotaPerMi = 1e6
+
+func metricsValue(e *exporter) {
+
+	e.iotaZmqValueMoved = prometheus.NewDesc(
+		"iota_zmq_value_moved_total",
+		"Sum of the positive values of the transactions seen by zeroMQ, in iota. Reattachments are counted again.",
+		nil, nil,
+	)
+
+	e.iotaZmqValueMovedMi = prometheus.NewDesc(
+		"iota_zmq_value_moved_mi_total",
+		"Sum of the positive values of the transactions seen by zeroMQ, in Mi.",
+		nil, nil,
+	)
+
+	e.iotaZmqValueConfirmed = prometheus.NewDesc(
+		"iota_zmq_value_confirmed_total",
+		"Sum of the positive values of the confirmed transactions seen by zeroMQ, in iota.",
+		nil, nil,
+	)
+
+	e.iotaZmqValueConfirmedRatio = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_value_confirmed_ratio",
+			Name: "iota_zmq_value_confirmed_ratio",
+			Help: "Confirmed value divided by the value seen by zeroMQ.",
+		})
+
+	e.iotaZmqValueSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_value_size",
+			Name:    "iota_zmq_value_size",
+			Help:    "Positive values of the transactions seen by zeroMQ, in iota.",
+			Buckets: prometheus.ExponentialBuckets(1, 10, 16),
+		})
+}
+
+func describeValue(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaZmqValueMoved
+	ch <- e.iotaZmqValueMovedMi
+	ch <- e.iotaZmqValueConfirmed
+	ch <- e.iotaZmqValueConfirmedRatio.Desc()
+	ch <- e.iotaZmqValueSize.Desc()
+}
+
+func collectValue(e *exporter, ch chan<- prometheus.Metric) {
+
+	zmqAccumsLock.Lock()
+	accums := zmqAccums
+	zmqAccumsLock.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(e.iotaZmqValueMoved, prometheus.CounterValue, accums.valueMoved)
+	ch <- prometheus.MustNewConstMetric(e.iotaZmqValueMovedMi, prometheus.CounterValue, accums.valueMoved/iotaPerMi)
+	ch <- prometheus.MustNewConstMetric(e.iotaZmqValueConfirmed, prometheus.CounterValue, accums.valueConfirmed)
+	ch <- e.iotaZmqValueConfirmedRatio
+	ch <- e.iotaZmqValueSize
+}
+
+func scrapeValue(e *exporter) {
+
+	zmqAccumsLock.Lock()
+	accums := zmqAccums
+	zmqAccumsLock.Unlock()
+
+	if accums.valueMoved > 0 {
+		e.iotaZmqValueConfirmedRatio.Set(accums.valueConfirmed / accums.valueMoved)
+	}
+}
+
+// seenValue accounts the value of a transaction seen by zeroMQ. Only outputs
+// are counted, inputs move the same value.
+func seenValue(e *exporter, tx *transaction) {
+	if tx.Value <= 0 {
+		return
+	}
+
+	e.iotaZmqValueSize.Observe(float64(tx.Value))
+	zmqAccumsLock.Lock()
+	zmqAccums.valueMoved += float64(tx.Value)
+	zmqAccumsLock.Unlock()
+}
+
+// confirmedValue accounts the value of a confirmed transaction.
+func confirmedValue(rec *txRecord) {
+	if rec.TxValue <= 0 {
+		return
+	}
+
+	zmqAccumsLock.Lock()
+	zmqAccums.valueConfirmed += float64(rec.TxValue)
+	zmqAccumsLock.Unlock()
+}
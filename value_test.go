@@ -0,0 +1,78 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+)
+
+func TestValueFlow(t *testing.T) {
+
+	zmqAccums = zmqAccumsf{}
+	defer func() { zmqAccums = zmqAccumsf{} }()
+
+	e := newExporter("")
+	seenValue(e, &transaction{Value: 3000000})
+	seenValue(e, &transaction{Value: -3000000})
+	seenValue(e, &transaction{Value: 1000000})
+	seenValue(e, &transaction{})
+	confirmedValue(&txRecord{TxValue: 1000000})
+	confirmedValue(&txRecord{TxValue: -3000000})
+	scrapeValue(e)
+
+	if zmqAccums.valueMoved != 4000000 || zmqAccums.valueConfirmed != 1000000 {
+		t.Errorf("Expected 4000000 moved and 1000000 confirmed, got %v and %v", zmqAccums.valueMoved, zmqAccums.valueConfirmed)
+	}
+	if r := testutil.ToFloat64(e.iotaZmqValueConfirmedRatio); r != 0.25 {
+		t.Errorf("Expected a confirmed ratio of 0.25, got %v", r)
+	}
+}
+
+func TestCollectValueGolden(t *testing.T) {
+
+	zmqAccumsLock.Lock()
+	saved := zmqAccums
+	zmqAccums = zmqAccumsf{}
+	zmqAccumsLock.Unlock()
+	defer func() {
+		zmqAccumsLock.Lock()
+		zmqAccums = saved
+		zmqAccumsLock.Unlock()
+	}()
+
+	e := newExporter("")
+	for _, value := range []int64{1500, -1500, 0, 2500000} {
+		seenValue(e, &transaction{Value: value})
+	}
+	confirmedValue(&txRecord{TxValue: 1500})
+	confirmedValue(&txRecord{TxValue: -1500})
+	scrapeValue(e)
+
+	checkGolden(t, "value", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectValue(e, ch)
+	})))
+}
@@ -0,0 +1,477 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/iotaledger/giota"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchEntry is an address in the watch list file and admin API.
+type watchEntry struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+}
+
+// watchPending is a value transaction of a watched address that is not
+// confirmed yet.
+type watchPending struct {
+	value int64
+	seen  time.Time
+}
+
+// watchedAddress holds the statistics of a watched address. Values are in
+// iota; sent values are kept as positive numbers.
+type watchedAddress struct {
+	label        string
+	txs          map[string]float64
+	seenValue    map[string]float64
+	confirmed    map[string]float64
+	pending      map[string]watchPending
+	balance      float64
+	balanceKnown bool
+}
+
+// watchStatus is the state of a watched address as returned by the admin API.
+type watchStatus struct {
+	Address       string   `json:"address"`
+	Label         string   `json:"label,omitempty"`
+	TxIn          float64  `json:"txIn"`
+	TxOut         float64  `json:"txOut"`
+	TxNone        float64  `json:"txNone"`
+	ReceivedValue float64  `json:"receivedValue"`
+	SentValue     float64  `json:"sentValue"`
+	PendingIn     float64  `json:"pendingIn"`
+	PendingOut    float64  `json:"pendingOut"`
+	ConfirmedIn   float64  `json:"confirmedIn"`
+	ConfirmedOut  float64  `json:"confirmedOut"`
+	Balance       *float64 `json:"balance,omitempty"`
+}
+
+// watchList follows the transactions of a list of addresses on the ZMQ
+// stream. The list is read from a file and can be changed through the admin
+// API, which writes it back to the file.
+type watchList struct {
+	sync.Mutex
+	file           string
+	pendingTimeout time.Duration
+	addresses      map[string]*watchedAddress
+
+	// saving serializes writes of the file, so an older snapshot cannot be
+	// renamed over a newer one.
+	saving sync.Mutex
+}
+
+func newWatchList(file string, pendingTimeout time.Duration) (*watchList, error) {
+	w := &watchList{
+		file:           file,
+		pendingTimeout: pendingTimeout,
+		addresses:      make(map[string]*watchedAddress),
+	}
+	if file == "" {
+		return w, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return w, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []watchEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, entry := range entries {
+		address, err := normalizeAddress(entry.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		w.addresses[address] = newWatchedAddress(entry.Label)
+	}
+	log.Infof("Watching %d addresses from %s.", len(w.addresses), file)
+	return w, nil
+}
+
+func newWatchedAddress(label string) *watchedAddress {
+	return &watchedAddress{
+		label:     label,
+		txs:       make(map[string]float64),
+		seenValue: make(map[string]float64),
+		confirmed: make(map[string]float64),
+		pending:   make(map[string]watchPending),
+	}
+}
+
+// normalizeAddress checks an address and strips its checksum.
+func normalizeAddress(address string) (string, error) {
+	if !isTrytes(address) || (len(address) != 81 && len(address) != 90) {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+	return address[:81], nil
+}
+
+// direction returns in for received values, out for sent values and none for
+// zero value transactions.
+func direction(value int64) string {
+	if value > 0 {
+		return "in"
+	} else if value < 0 {
+		return "out"
+	}
+	return "none"
+}
+
+func (w *watchList) add(address, label string) error {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	w.Lock()
+	if wa, ok := w.addresses[address]; ok {
+		wa.label = label
+	} else {
+		w.addresses[address] = newWatchedAddress(label)
+	}
+	w.Unlock()
+
+	return w.save()
+}
+
+func (w *watchList) remove(address string) (bool, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return false, err
+	}
+
+	w.Lock()
+	_, ok := w.addresses[address]
+	delete(w.addresses, address)
+	w.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, w.save()
+}
+
+// save writes the watch list back to its file, if it has one.
+func (w *watchList) save() error {
+	if w.file == "" {
+		return nil
+	}
+
+	w.saving.Lock()
+	defer w.saving.Unlock()
+
+	w.Lock()
+	entries := make([]watchEntry, 0, len(w.addresses))
+	for address, wa := range w.addresses {
+		entries = append(entries, watchEntry{Address: address, Label: wa.label})
+	}
+	w.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Address < entries[j].Address })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := w.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.file)
+}
+
+// seen records a transaction if it touches a watched address.
+func (w *watchList) seen(tx *transaction, now time.Time) {
+	w.Lock()
+	defer w.Unlock()
+
+	wa, ok := w.addresses[tx.Address]
+	if !ok {
+		return
+	}
+
+	dir := direction(tx.Value)
+	wa.txs[dir]++
+	if tx.Value != 0 {
+		wa.seenValue[dir] += abs(tx.Value)
+		wa.pending[tx.Hash] = watchPending{value: tx.Value, seen: now}
+	}
+}
+
+// confirmed moves the value of a confirmed transaction from pending to
+// confirmed.
+func (w *watchList) confirmed(sn *sn) {
+	w.Lock()
+	defer w.Unlock()
+
+	wa, ok := w.addresses[sn.AddressHash]
+	if !ok {
+		return
+	}
+	p, ok := wa.pending[sn.Hash]
+	if !ok {
+		return
+	}
+
+	wa.confirmed[direction(p.value)] += abs(p.value)
+	delete(wa.pending, sn.Hash)
+}
+
+// expire forgets pending transactions older than the pending timeout, which
+// are mostly reattachments of transfers confirmed through another tx.
+func (w *watchList) expire(now time.Time) {
+	w.Lock()
+	defer w.Unlock()
+
+	for _, wa := range w.addresses {
+		for hash, p := range wa.pending {
+			if now.Sub(p.seen) > w.pendingTimeout {
+				delete(wa.pending, hash)
+			}
+		}
+	}
+}
+
+func (w *watchList) status() []watchStatus {
+	w.Lock()
+	defer w.Unlock()
+
+	list := make([]watchStatus, 0, len(w.addresses))
+	for address, wa := range w.addresses {
+		s := watchStatus{
+			Address:       address,
+			Label:         wa.label,
+			TxIn:          wa.txs["in"],
+			TxOut:         wa.txs["out"],
+			TxNone:        wa.txs["none"],
+			ReceivedValue: wa.seenValue["in"],
+			SentValue:     wa.seenValue["out"],
+			ConfirmedIn:   wa.confirmed["in"],
+			ConfirmedOut:  wa.confirmed["out"],
+		}
+		for _, p := range wa.pending {
+			if p.value > 0 {
+				s.PendingIn += float64(p.value)
+			} else {
+				s.PendingOut -= float64(p.value)
+			}
+		}
+		if wa.balanceKnown {
+			balance := wa.balance
+			s.Balance = &balance
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
+
+// checkBalances verifies the balances of the watched addresses with IRI.
+func (w *watchList) checkBalances(api *giota.API) error {
+	w.Lock()
+	addresses := make([]giota.Address, 0, len(w.addresses))
+	for address := range w.addresses {
+		addresses = append(addresses, giota.Address(address))
+	}
+	w.Unlock()
+
+	if len(addresses) == 0 {
+		return nil
+	}
+	resp, err := api.GetBalances(addresses, 100)
+	if err != nil {
+		return err
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	for i, balance := range resp.Balances {
+		if i >= len(addresses) {
+			break
+		}
+		if wa, ok := w.addresses[string(addresses[i])]; ok {
+			wa.balance = float64(balance)
+			wa.balanceKnown = true
+		}
+	}
+	return nil
+}
+
+// checkBalancesLoop verifies the balances every interval.
+func (w *watchList) checkBalancesLoop(iriAddress string, interval time.Duration) {
+	api := giota.NewAPI(iriAddress, nil)
+	for {
+		if err := w.checkBalances(api); err != nil {
+			log.Info(err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// handleWatch lists the watched addresses (GET) or adds one (POST).
+func (w *watchList) handleWatch(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(rw, http.StatusOK, w.status())
+
+	case "POST":
+		if !authorized(rw, r) {
+			return
+		}
+		entry := watchEntry{}
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			writeError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := w.add(entry.Address, entry.Label); err != nil {
+			writeError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(rw, http.StatusCreated, entry)
+
+	default:
+		writeError(rw, http.StatusMethodNotAllowed, "use GET or POST")
+	}
+}
+
+// handleWatchAddress removes an address from the watch list (DELETE).
+func (w *watchList) handleWatchAddress(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		writeError(rw, http.StatusMethodNotAllowed, "use DELETE")
+		return
+	}
+	if !authorized(rw, r) {
+		return
+	}
+
+	address := strings.TrimPrefix(r.URL.Path, "/api/v1/watch/")
+	removed, err := w.remove(address)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	} else if !removed {
+		writeError(rw, http.StatusNotFound, "address is not watched")
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func initWatch(e *exporter) {
+	w, err := newWatchList(*watchFile, *watchPendingTimeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	e.watch = w
+
+	if *watchBalanceInterval > 0 {
+		go w.checkBalancesLoop(e.iriAddress, *watchBalanceInterval)
+	}
+}
+
+func metricsWatch(e *exporter) {
+
+	labels := []string{"address", "label", "direction"}
+
+	e.iotaWatchTxs = prometheus.NewDesc(
+		"iota_watch_tx_total",
+		"Transactions seen for a watched address, by direction (in, out or none for zero value).",
+		labels, nil,
+	)
+
+	e.iotaWatchSeenValue = prometheus.NewDesc(
+		"iota_watch_seen_value_total",
+		"Value in iota received (in) or sent (out) by a watched address as seen by zeroMQ, including reattachments.",
+		labels, nil,
+	)
+
+	e.iotaWatchPendingValue = prometheus.NewDesc(
+		"iota_watch_pending_value",
+		"Value in iota of the transactions of a watched address that are not confirmed yet.",
+		labels, nil,
+	)
+
+	e.iotaWatchConfirmedValue = prometheus.NewDesc(
+		"iota_watch_confirmed_value_total",
+		"Value in iota received (in) or sent (out) by a watched address that was confirmed.",
+		labels, nil,
+	)
+
+	e.iotaWatchBalance = prometheus.NewDesc(
+		"iota_watch_balance",
+		"Balance in iota of a watched address according to getBalances.",
+		[]string{"address", "label"}, nil,
+	)
+}
+
+func describeWatch(e *exporter, ch chan<- *prometheus.Desc) {
+	ch <- e.iotaWatchTxs
+	ch <- e.iotaWatchSeenValue
+	ch <- e.iotaWatchPendingValue
+	ch <- e.iotaWatchConfirmedValue
+	ch <- e.iotaWatchBalance
+}
+
+func collectWatch(e *exporter, ch chan<- prometheus.Metric) {
+	if e.watch == nil {
+		return
+	}
+
+	for _, s := range e.watch.status() {
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchTxs, prometheus.CounterValue, s.TxIn, s.Address, s.Label, "in")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchTxs, prometheus.CounterValue, s.TxOut, s.Address, s.Label, "out")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchTxs, prometheus.CounterValue, s.TxNone, s.Address, s.Label, "none")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchSeenValue, prometheus.CounterValue, s.ReceivedValue, s.Address, s.Label, "in")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchSeenValue, prometheus.CounterValue, s.SentValue, s.Address, s.Label, "out")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchPendingValue, prometheus.GaugeValue, s.PendingIn, s.Address, s.Label, "in")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchPendingValue, prometheus.GaugeValue, s.PendingOut, s.Address, s.Label, "out")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchConfirmedValue, prometheus.CounterValue, s.ConfirmedIn, s.Address, s.Label, "in")
+		ch <- prometheus.MustNewConstMetric(e.iotaWatchConfirmedValue, prometheus.CounterValue, s.ConfirmedOut, s.Address, s.Label, "out")
+		if s.Balance != nil {
+			ch <- prometheus.MustNewConstMetric(e.iotaWatchBalance, prometheus.GaugeValue, *s.Balance, s.Address, s.Label)
+		}
+	}
+}
+
+func scrapeWatch(e *exporter) {
+	if e.watch == nil {
+		return
+	}
+	e.watch.expire(time.Now())
+}
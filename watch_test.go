@@ -0,0 +1,209 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchList(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "watch.json")
+
+	w, err := newWatchList(file, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := syntheticHash("ADDR", 1)
+	if err := w.add(address+"ABCDEFGHI", "exchange"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.add("NOT-AN-ADDRESS", ""); err == nil {
+		t.Errorf("Expected an error for an invalid address")
+	}
+
+	// The list is written back and read again
+	w, err = newWatchList(file, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.addresses[address]; !ok {
+		t.Fatalf("Expected %v to be watched after reload", address)
+	}
+
+	now := time.Now()
+	w.seen(&transaction{Hash: "IN", Address: address, Value: 100}, now)
+	w.seen(&transaction{Hash: "OUT", Address: address, Value: -40}, now)
+	w.seen(&transaction{Hash: "ZERO", Address: address}, now)
+	w.seen(&transaction{Hash: "OTHER", Address: syntheticHash("ADDR", 2), Value: 5}, now)
+	w.confirmed(&sn{Hash: "IN", AddressHash: address})
+
+	s := w.status()[0]
+	if s.TxIn != 1 || s.TxOut != 1 || s.TxNone != 1 || s.Label != "exchange" {
+		t.Errorf("Test counts: Expected 1 in, 1 out and 1 none, got %+v", s)
+	}
+	if s.ConfirmedIn != 100 || s.PendingIn != 0 || s.PendingOut != 40 || s.SentValue != 40 {
+		t.Errorf("Test values: Expected 100 confirmed in and 40 pending out, got %+v", s)
+	}
+
+	e := newExporter("")
+	e.watch = w
+	out := exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) { collectWatch(e, ch) }))
+	none := `iota_watch_tx_total{address="` + address + `",direction="none",label="exchange"} 1`
+	if !strings.Contains(string(out), none) {
+		t.Errorf("Test metrics: Expected %v, got %s", none, out)
+	}
+
+	w.expire(now.Add(2 * time.Hour))
+	if s := w.status()[0]; s.PendingOut != 0 {
+		t.Errorf("Test expire: Expected no pending value, got %v", s.PendingOut)
+	}
+}
+
+func TestWatchListConcurrentSave(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "watch.json")
+
+	w, _ := newWatchList(file, time.Hour)
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if err := w.add(syntheticHash("ADDR", n), ""); err != nil {
+				t.Error(err)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	saved, err := newWatchList(file, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(saved.status()); n != 20 {
+		t.Errorf("Expected the file to hold all 20 addresses, got %v", n)
+	}
+}
+
+func TestWatchAPIDisabled(t *testing.T) {
+
+	w, _ := newWatchList("", time.Hour)
+	r := httptest.NewRequest("POST", "/api/v1/watch", strings.NewReader(`{"address":"`+syntheticHash("ADDR", 3)+`"}`))
+	rw := httptest.NewRecorder()
+	w.handleWatch(rw, r)
+
+	if rw.Code != http.StatusForbidden || len(w.status()) != 0 {
+		t.Errorf("Expected the watch list to be read only without an admin token, got status %v", rw.Code)
+	}
+}
+
+func TestWatchAPI(t *testing.T) {
+
+	*adminToken = "secret"
+	defer func() { *adminToken = "" }()
+
+	w, _ := newWatchList("", time.Hour)
+	address := syntheticHash("ADDR", 3)
+
+	for _, test := range []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{"POST", "/api/v1/watch", "", `{"address":"` + address + `"}`, http.StatusUnauthorized},
+		{"POST", "/api/v1/watch", "secret", `{"address":"` + address + `","label":"cold"}`, http.StatusCreated},
+		{"GET", "/api/v1/watch", "", "", http.StatusOK},
+		{"DELETE", "/api/v1/watch/" + address, "wrong", "", http.StatusUnauthorized},
+		{"DELETE", "/api/v1/watch/" + address, "secret", "", http.StatusNoContent},
+		{"DELETE", "/api/v1/watch/" + address, "secret", "", http.StatusNotFound},
+	} {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rw := httptest.NewRecorder()
+		if strings.HasPrefix(test.path, "/api/v1/watch/") {
+			w.handleWatchAddress(rw, r)
+		} else {
+			w.handleWatch(rw, r)
+		}
+		if rw.Code != test.status {
+			t.Errorf("Test %v %v: Expected status %v, got %v", test.method, test.path, test.status, rw.Code)
+		}
+	}
+}
+
+func TestCollectWatchGolden(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := newWatchList(filepath.Join(dir, "watch.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, other := syntheticHash("ADDR", 1), syntheticHash("ADDR", 2)
+	if err := w.add(address, "exchange"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.add(other, ""); err != nil {
+		t.Fatal(err)
+	}
+	w.addresses[other].balance, w.addresses[other].balanceKnown = 42, true
+
+	now := time.Now()
+	w.seen(&transaction{Hash: "IN", Address: address, Value: 100}, now)
+	w.seen(&transaction{Hash: "OUT", Address: address, Value: -40}, now)
+	w.seen(&transaction{Hash: "ZERO", Address: address}, now)
+	w.confirmed(&sn{Hash: "IN", AddressHash: address})
+
+	e := newExporter("")
+	e.watch = w
+	checkGolden(t, "watch", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectWatch(e, ch)
+	})))
+}
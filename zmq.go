@@ -25,13 +25,12 @@ SOFTWARE.
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"github.com/dgraph-io/badger"
 	"github.com/pebbe/zmq4"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -46,6 +45,8 @@ type zmqAccumsf struct {
 	txToReply        float64
 	txNumberStoredTx float64
 	txTxnToRequest   float64
+	valueMoved       float64
+	valueConfirmed   float64
 }
 
 type transaction struct {
@@ -85,15 +86,36 @@ type txRecord struct {
 	TxConfirmed int64
 	TxAddress   string
 	TxValue     int64
+	MilestoneIn int64
+	Category    string
 }
 
 type zmqConfirmation struct {
-	label    string
-	duration float64
+	label      string
+	duration   float64
+	milestones float64
+	known      bool // whether the milestone at arrival is known
+	category   string
 }
 
 var zmqAccums zmqAccumsf
+var zmqAccumsLock sync.Mutex
 var zmqConfirmationSet []zmqConfirmation
+var zmqConfirmationLock sync.Mutex
+
+// zmqLatestMilestone holds the most recent milestone index seen in a sn or lmi
+// message or reported by getNodeInfo, 0 while it is unknown.
+var zmqLatestMilestone int64
+
+// observeLatestMilestone raises zmqLatestMilestone to index.
+func observeLatestMilestone(index int64) {
+	for {
+		latest := atomic.LoadInt64(&zmqLatestMilestone)
+		if index <= latest || atomic.CompareAndSwapInt64(&zmqLatestMilestone, latest, index) {
+			return
+		}
+	}
+}
 
 func getTxLabel(c int64) string {
 	label := "0"
@@ -179,18 +201,94 @@ func metricsZmq(e *exporter) {
 			Help: "totalTransactions from RSTAT output of ZMQ.",
 		})
 
-	e.iotaZmqConfirmationHisto = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
+	confirmBuckets, err := parseBuckets(*zmqConfirmBuckets)
+	if err != nil {
+		log.Fatalf("Invalid --zmq.confirm-buckets: %v", err)
+	}
+
+	e.iotaZmqConfirmationHisto = newPersistentHistogramVec(
+		"iota_zmq_tx_confirm_time",
+		"Actual seconds it takes to confirm each tx.",
+		confirmBuckets, "hasValue",
+	)
+
+	e.iotaZmqConfirmationSummary = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
 			//Namespace: namespace,
 			//Subsystem: "zmq",
-			//Name: "zmq_total_transactions",
-			Name:    "iota_zmq_tx_confirm_time",
-			Help:    "Actual seconds it takes to confirm each tx.",
-			Buckets: []float64{300, 600, 1200, 2400, 3600, 7200, 21600, 43200},
+			//Name: "zmq_tx_confirm_time_summary",
+			Name:       "iota_zmq_tx_confirm_time_summary",
+			Help:       "Seconds it takes to confirm each tx over a sliding window.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			MaxAge:     *zmqConfirmWindow,
 		},
 		[]string{"hasValue"},
 	)
 
+	milestoneBuckets, err := parseBuckets(*zmqConfirmMilestoneBuckets)
+	if err != nil {
+		log.Fatalf("Invalid --zmq.confirm-milestone-buckets: %v", err)
+	}
+
+	e.iotaZmqConfirmationMilestonesHisto = newPersistentHistogramVec(
+		"iota_zmq_tx_confirm_milestones",
+		"Milestones issued between first seeing a tx and its confirmation.",
+		milestoneBuckets, "hasValue",
+	)
+
+	e.iotaZmqSeenTxTotal = prometheus.NewDesc(
+		"iota_zmq_seen_tx_total",
+		"Transactions seen by zeroMQ, kept across restarts.",
+		[]string{"hasValue"}, nil,
+	)
+
+	e.iotaZmqConfirmedTxTotal = prometheus.NewDesc(
+		"iota_zmq_confirmed_tx_total",
+		"Transactions confirmed by zeroMQ, kept across restarts.",
+		nil, nil,
+	)
+
+	e.iotaZmqQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_queue_depth",
+			Name: "iota_zmq_queue_depth",
+			Help: "ZMQ messages waiting for a database worker.",
+		})
+
+	e.iotaZmqDroppedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_dropped_messages_total",
+			Name: "iota_zmq_dropped_messages_total",
+			Help: "ZMQ messages dropped because the database workers could not keep up.",
+		},
+		[]string{"topic"},
+	)
+
+	e.iotaZmqQueueLag = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_queue_lag_seconds",
+			Name: "iota_zmq_queue_lag_seconds",
+			Help: "Seconds the last ZMQ message handled by a database worker waited in the queue, or its age while messages are still queued.",
+		})
+
+	e.iotaZmqDBWriteDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_db_write_seconds",
+			Name:    "iota_zmq_db_write_seconds",
+			Help:    "Time spent writing a batch of new transactions (put) or a confirmation (confirm) to the database.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+		},
+		[]string{"op"},
+	)
+
 }
 
 func describeZmq(e *exporter, ch chan<- *prometheus.Desc) {
@@ -203,7 +301,15 @@ func describeZmq(e *exporter, ch chan<- *prometheus.Desc) {
 	ch <- e.iotaZmqToRequest.Desc()
 	ch <- e.iotaZmqToReply.Desc()
 	ch <- e.iotaZmqTotalTransactions.Desc()
-	e.iotaZmqConfirmationHisto.Describe(ch)
+	ch <- e.iotaZmqConfirmationHisto.desc
+	e.iotaZmqConfirmationSummary.Describe(ch)
+	ch <- e.iotaZmqConfirmationMilestonesHisto.desc
+	ch <- e.iotaZmqSeenTxTotal
+	ch <- e.iotaZmqConfirmedTxTotal
+	ch <- e.iotaZmqQueueDepth.Desc()
+	e.iotaZmqDroppedMessages.Describe(ch)
+	ch <- e.iotaZmqQueueLag.Desc()
+	e.iotaZmqDBWriteDuration.Describe(ch)
 }
 
 func collectZmq(e *exporter, ch chan<- prometheus.Metric) {
@@ -216,53 +322,109 @@ func collectZmq(e *exporter, ch chan<- prometheus.Metric) {
 	ch <- e.iotaZmqToRequest
 	ch <- e.iotaZmqToReply
 	ch <- e.iotaZmqTotalTransactions
-	e.iotaZmqConfirmationHisto.Collect(ch)
+	e.iotaZmqConfirmationHisto.collect(ch)
+	e.iotaZmqConfirmationSummary.Collect(ch)
+	e.iotaZmqConfirmationMilestonesHisto.collect(ch)
+
+	zmqAccumsLock.Lock()
+	accums := zmqAccums
+	zmqAccumsLock.Unlock()
+	ch <- prometheus.MustNewConstMetric(e.iotaZmqSeenTxTotal, prometheus.CounterValue, accums.txAnyNotZero, "<> 0")
+	ch <- prometheus.MustNewConstMetric(e.iotaZmqSeenTxTotal, prometheus.CounterValue, accums.txAnyZero, "0")
+	ch <- prometheus.MustNewConstMetric(e.iotaZmqConfirmedTxTotal, prometheus.CounterValue, accums.txConfirmed)
+	ch <- e.iotaZmqQueueDepth
+	e.iotaZmqDroppedMessages.Collect(ch)
+	ch <- e.iotaZmqQueueLag
+	e.iotaZmqDBWriteDuration.Collect(ch)
 }
 
 func scrapeZmq(e *exporter) {
 
-	e.iotaZmqSeenTxCount.WithLabelValues("<> 0").Set(zmqAccums.txAnyNotZero)
-	e.iotaZmqSeenTxCount.WithLabelValues("0").Set(zmqAccums.txAnyZero)
-	e.iotaZmqTxsWithValueCount.Set(zmqAccums.txValue)
-	e.iotaZmqConfirmedTxCount.Set(zmqAccums.txConfirmed)
-	e.iotaZmqToProcess.Set(zmqAccums.txToProcess)
-	e.iotaZmqToBroadcast.Set(zmqAccums.txToBroadcast)
-	e.iotaZmqToRequest.Set(zmqAccums.txTxnToRequest)
-	e.iotaZmqToReply.Set(zmqAccums.txToReply)
-	e.iotaZmqTotalTransactions.Set(zmqAccums.txTotal)
-
-	for i := range zmqConfirmationSet {
-		e.iotaZmqConfirmationHisto.WithLabelValues(zmqConfirmationSet[i].label).Observe(zmqConfirmationSet[i].duration)
+	zmqAccumsLock.Lock()
+	accums := zmqAccums
+	zmqAccumsLock.Unlock()
+
+	e.iotaZmqSeenTxCount.WithLabelValues("<> 0").Set(accums.txAnyNotZero)
+	e.iotaZmqSeenTxCount.WithLabelValues("0").Set(accums.txAnyZero)
+	e.iotaZmqTxsWithValueCount.Set(accums.txValue)
+	e.iotaZmqConfirmedTxCount.Set(accums.txConfirmed)
+	e.iotaZmqToProcess.Set(accums.txToProcess)
+	e.iotaZmqToBroadcast.Set(accums.txToBroadcast)
+	e.iotaZmqToRequest.Set(accums.txTxnToRequest)
+	e.iotaZmqToReply.Set(accums.txToReply)
+	e.iotaZmqTotalTransactions.Set(accums.txTotal)
+	if e.zmq != nil {
+		e.iotaZmqQueueDepth.Set(float64(len(e.zmq.queue)))
+		e.zmq.observeLag(time.Now())
 	}
-	zmqConfirmationSet = nil
 
-	log.Debugf("total tx:         %v tx", int64(zmqAccums.txTotal))
-	log.Debugf("txAnyZero:        %v tx", int64(zmqAccums.txAnyZero))
-	log.Debugf("txAnyNotZero:     %v tx", int64(zmqAccums.txAnyNotZero))
-	log.Debugf("txValue:          %v tx", int64(zmqAccums.txValue))
-	log.Debugf("txConfirmed:      %v tx", int64(zmqAccums.txConfirmed))
-	log.Debugf("txToProcess:      %v tx", int64(zmqAccums.txToProcess))
-	log.Debugf("txToBroadcast:    %v tx", int64(zmqAccums.txToBroadcast))
-	log.Debugf("txToReply:        %v tx", int64(zmqAccums.txToReply))
-	log.Debugf("txNumberStoredTx: %v tx", int64(zmqAccums.txNumberStoredTx))
-	log.Debugf("txTxnToRequest:   %v tx", int64(zmqAccums.txTxnToRequest))
+	drainZmqConfirmations(e)
 
+	log.Debugf("total tx:         %v tx", int64(accums.txTotal))
+	log.Debugf("txAnyZero:        %v tx", int64(accums.txAnyZero))
+	log.Debugf("txAnyNotZero:     %v tx", int64(accums.txAnyNotZero))
+	log.Debugf("txValue:          %v tx", int64(accums.txValue))
+	log.Debugf("txConfirmed:      %v tx", int64(accums.txConfirmed))
+	log.Debugf("txToProcess:      %v tx", int64(accums.txToProcess))
+	log.Debugf("txToBroadcast:    %v tx", int64(accums.txToBroadcast))
+	log.Debugf("txToReply:        %v tx", int64(accums.txToReply))
+	log.Debugf("txNumberStoredTx: %v tx", int64(accums.txNumberStoredTx))
+	log.Debugf("txTxnToRequest:   %v tx", int64(accums.txTxnToRequest))
+
+}
+
+// drainZmqConfirmations moves the confirmations measured by the workers into
+// the confirmation histograms.
+func drainZmqConfirmations(e *exporter) {
+
+	zmqConfirmationLock.Lock()
+	set := zmqConfirmationSet
+	zmqConfirmationSet = nil
+	zmqConfirmationLock.Unlock()
+
+	for i := range set {
+		c := set[i]
+		e.iotaZmqConfirmationHisto.observe(c.label, c.duration)
+		e.iotaZmqConfirmationSummary.WithLabelValues(c.label).Observe(c.duration)
+		if c.known {
+			e.iotaZmqConfirmationMilestonesHisto.observe(c.label, c.milestones)
+		}
+		if c.category != "" {
+			e.iotaZmqCategoryConfirmed.WithLabelValues(c.category).Inc()
+			e.iotaZmqCategoryConfirmTime.WithLabelValues(c.category).Observe(c.duration)
+		}
+	}
 }
 
-func collectZmqAccums(address *string) {
+// zmqTopics are the ZMQ topics the exporter subscribes to.
+var zmqTopics = []string{"tx", "sn", "lmi", "rstat"}
+
+// zmqReceiveTimeout is how long no message may arrive before reconnecting.
+var zmqReceiveTimeout = 10 * time.Second
+
+// collectZmqAccums receives the ZMQ messages of IRI until stop is closed. A
+// nil stop channel receives until the exporter exits.
+func collectZmqAccums(address *string, e *exporter, stop <-chan struct{}) {
+
+	if *zmqReplayFile != "" {
+		if err := replayZmq(e, *zmqReplayFile, *zmqReplaySpeed); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	for {
 
 		socket, err := zmq4.NewSocket(zmq4.SUB)
 		must(err)
 
-		for _, topic := range []string{"tx", "sn", "rstat"} {
+		for _, topic := range zmqTopics {
 			err = socket.SetSubscribe(topic)
 			must(err)
 		}
 
 		// Set ZMQ no received messages time-out
-		err = socket.SetRcvtimeo(10 * time.Second)
+		err = socket.SetRcvtimeo(zmqReceiveTimeout)
 		must(err)
 
 		err = socket.Connect(*address)
@@ -270,171 +432,268 @@ func collectZmqAccums(address *string) {
 
 		log.Infof("Connected to IRI at address %s.", *address)
 
-		// Start Badger Database
-		opts := badger.DefaultOptions
-		opts.Dir = *databasePath
-		opts.ValueDir = *databasePath
-		db, err := badger.Open(opts)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer db.Close()
-
-		// Run Database Cleanup on interval
-		go badgerDBCleanup(db)
-
 		for {
 
+			select {
+			case <-stop:
+				socket.Close()
+				return
+			default:
+			}
+
 			msg, err := socket.Recv(0)
 			if err == zmq4.ETIMEDOUT {
 				log.Info("No ZMQ RStat msg received, reconnecting to zmq socket.")
+				socket.Close()
 				break
 			} else if err != nil {
 				panic(err)
 			}
 
-			parts := strings.Fields(msg)
-			switch parts[0] {
-
-			// Transaction
-			case "tx":
-				zmqAccums.txTotal++
-				tx := transaction{
-					Hash:         parts[1],
-					Address:      parts[2],
-					Value:        stoi(parts[3]),
-					Tag:          parts[4],
-					Timestamp:    parts[5],
-					CurrentIndex: parts[6],
-					LastIndex:    parts[7],
-					Bundle:       parts[8],
-					Trunk:        parts[9],
-					Branch:       parts[10],
-					ArrivalDate:  parts[11],
-				}
-
-				processValueTx(db, &tx)
-				if tx.Value != 0 {
-					zmqAccums.txAnyNotZero++
-					zmqAccums.txValue++
-					log.Debug("ZMQ Tx with value msg received.")
-				} else {
-					zmqAccums.txAnyZero++
-					//log.Debug("ZMQ Tx with zero value msg received.")
-				}
-
-			// Confirmed Transaction
-			case "sn":
-				sn := sn{
-					Index:       parts[1],
-					Hash:        parts[2],
-					AddressHash: parts[3],
-					Trunk:       parts[4],
-					Branch:      parts[5],
-					Bundle:      parts[6],
-				}
-				zmqAccums.txConfirmed++
-				log.Debug("ZMQ Confirmed Tx msg received.")
-				go processConfirmedTx(db, &sn)
-
-			// RStat message (overall statistics)
-			case "rstat":
-				stat := queue{
-					ReceiveQueueSize:   stoi(parts[1]),
-					BroadcastQueueSize: stoi(parts[2]),
-					TxnToRequest:       stoi(parts[3]),
-					ReplyQueueSize:     stoi(parts[4]),
-					NumberOfStoredTxns: stoi(parts[5]),
-				}
-
-				// Note that these are total counts, no need to increment into the timeslice
-				zmqAccums.txToProcess = float64(stat.ReceiveQueueSize)
-				zmqAccums.txToBroadcast = float64(stat.BroadcastQueueSize)
-				zmqAccums.txToReply = float64(stat.ReplyQueueSize)
-				zmqAccums.txNumberStoredTx = float64(stat.NumberOfStoredTxns)
-				zmqAccums.txTxnToRequest = float64(stat.TxnToRequest)
-
-				log.Debug("ZMQ RStat msg received.")
-			}
+			handleZmqMessage(e, msg)
 		}
 	}
 }
 
-func processValueTx(db *badger.DB, tx *transaction) {
+// handleZmqMessage updates the accumulators for a single ZMQ message, feeds
+// it to the trackers and queues the database work it needs on the pipeline.
+func handleZmqMessage(e *exporter, msg string) {
 
-	recttl := 15 * 24 * time.Hour // 15 Days
-	err := db.Update(func(txn *badger.Txn) error {
+	parts := strings.Fields(msg)
+	if len(parts) == 0 {
+		return
+	}
 
-		key := fmt.Sprintf("%s", tx.Hash)
+	switch parts[0] {
 
-		rec := txRecord{
-			Timestamp:   stoi(tx.Timestamp),
-			TxIn:        stoi(time.Now().UTC().Format("20060102150405")),
-			TxConfirmed: 0,
-			TxAddress:   tx.Address,
-			TxValue:     tx.Value,
+	// Transaction
+	case "tx":
+		if len(parts) < 12 {
+			log.Debugf("Malformed ZMQ tx msg: %s", msg)
+			return
+		}
+		tx := transaction{
+			Hash:         parts[1],
+			Address:      parts[2],
+			Value:        stoi(parts[3]),
+			Tag:          parts[4],
+			Timestamp:    parts[5],
+			CurrentIndex: parts[6],
+			LastIndex:    parts[7],
+			Bundle:       parts[8],
+			Trunk:        parts[9],
+			Branch:       parts[10],
+			ArrivalDate:  parts[11],
 		}
 
-		val, err := json.Marshal(rec)
-		log.Debugf("BadgerDB write: key(%s) value(%s)", key, val)
-		err = txn.SetWithTTL([]byte(key), val, recttl)
-
-		return err
-	})
-	if err != nil {
-		log.Infof("BadgerDB error %v.", err)
-	}
-}
-
-func processConfirmedTx(db *badger.DB, tx *sn) {
+		now := time.Now()
+		job := zmqJob{received: now, milestone: atomic.LoadInt64(&zmqLatestMilestone), tx: &tx}
+		if e.categories != nil {
+			job.category = e.categories.classify(&tx)
+			e.iotaZmqCategorySeen.WithLabelValues(job.category).Inc()
+		}
+		if tx.Value == 0 && e.zeroValue != nil {
+			e.zeroValue.add(tx.Hash, now)
+		}
+		if tx.Value != 0 || !*databaseSkipZeroValue {
+			e.zmq.enqueue(job)
+		}
+		zmqSeenRate.add(now, 1)
+		seenValue(e, &tx)
+		observeArrival(e, &tx, now)
+		if e.bundles != nil {
+			e.bundles.seen(&tx, now)
+		}
+		if e.watch != nil {
+			e.watch.seen(&tx, now)
+		}
+		if e.finality != nil {
+			e.finality.seen(&tx)
+		}
+		if e.top != nil {
+			e.top.seen(&tx, now)
+		}
+		if e.tangle != nil {
+			e.tangle.seen(&tx, now)
+		}
+		if e.conflicts != nil {
+			e.conflicts.seen(&tx, now)
+		}
+		if e.spam != nil {
+			e.spam.seen(&tx)
+		}
+		zmqAccumsLock.Lock()
+		zmqAccums.txTotal++
+		if tx.Value != 0 {
+			zmqAccums.txAnyNotZero++
+			zmqAccums.txValue++
+			log.Debug("ZMQ Tx with value msg received.")
+		} else {
+			zmqAccums.txAnyZero++
+			//log.Debug("ZMQ Tx with zero value msg received.")
+		}
+		zmqAccumsLock.Unlock()
 
-	recttl := 24 * time.Hour // 1 Day
-	err := db.Update(func(txn *badger.Txn) error {
-		key := fmt.Sprintf("%s", tx.Hash)
-		val, err := txn.Get([]byte(key))
+	// Confirmed Transaction
+	case "sn":
+		if len(parts) < 7 {
+			log.Debugf("Malformed ZMQ sn msg: %s", msg)
+			return
+		}
+		sn := sn{
+			Index:       parts[1],
+			Hash:        parts[2],
+			AddressHash: parts[3],
+			Trunk:       parts[4],
+			Branch:      parts[5],
+			Bundle:      parts[6],
+		}
+		now := time.Now()
+		zmqConfirmedRate.add(now, 1)
+		zmqAccumsLock.Lock()
+		zmqAccums.txConfirmed++
+		zmqAccumsLock.Unlock()
+		observeLatestMilestone(stoi(sn.Index))
+		e.milestones.observe(stoi(sn.Index), now)
+		log.Debug("ZMQ Confirmed Tx msg received.")
+		e.zmq.enqueue(zmqJob{received: now, sn: &sn})
+		if e.bundles != nil {
+			e.bundles.confirmed(&sn, now)
+		}
+		if e.watch != nil {
+			e.watch.confirmed(&sn)
+		}
+		if e.finality != nil {
+			e.finality.confirmed(&sn, now)
+		}
+		if e.conflicts != nil {
+			e.conflicts.confirmed(&sn)
+		}
 
-		if err != badger.ErrKeyNotFound {
+	// Latest milestone index changed
+	case "lmi":
+		if len(parts) < 3 {
+			log.Debugf("Malformed ZMQ lmi msg: %s", msg)
+			return
+		}
+		observeLatestMilestone(stoi(parts[2]))
+		e.milestones.observe(stoi(parts[2]), time.Now())
+
+	// RStat message (overall statistics)
+	case "rstat":
+		if len(parts) < 6 {
+			log.Debugf("Malformed ZMQ rstat msg: %s", msg)
+			return
+		}
+		stat := queue{
+			ReceiveQueueSize:   stoi(parts[1]),
+			BroadcastQueueSize: stoi(parts[2]),
+			TxnToRequest:       stoi(parts[3]),
+			ReplyQueueSize:     stoi(parts[4]),
+			NumberOfStoredTxns: stoi(parts[5]),
+		}
 
-			v, _ := val.Value()
-			log.Debugf("BadgerDB get: key(%s) value(%s)", key, v)
+		// Note that these are total counts, no need to increment into the timeslice
+		zmqAccumsLock.Lock()
+		zmqAccums.txToProcess = float64(stat.ReceiveQueueSize)
+		zmqAccums.txToBroadcast = float64(stat.BroadcastQueueSize)
+		zmqAccums.txToReply = float64(stat.ReplyQueueSize)
+		zmqAccums.txNumberStoredTx = float64(stat.NumberOfStoredTxns)
+		zmqAccums.txTxnToRequest = float64(stat.TxnToRequest)
+		zmqAccumsLock.Unlock()
 
-			rec := txRecord{}
-			json.Unmarshal(v, &rec)
+		log.Debug("ZMQ RStat msg received.")
+	}
+}
 
-			log.Infof("rec: %v.", rec)
+// processValueTx returns the database record for a newly seen transaction.
+func processValueTx(job *zmqJob) storeEntry {
+
+	tx := job.tx
+	rec := txRecord{
+		Timestamp:   stoi(tx.Timestamp),
+		TxIn:        recordTime(job.received),
+		TxConfirmed: 0,
+		TxAddress:   tx.Address,
+		TxValue:     tx.Value,
+		MilestoneIn: job.milestone,
+		Category:    job.category,
+	}
 
-			rec.TxConfirmed = stoi(time.Now().UTC().Format("20060102150405"))
-			v, _ = json.Marshal(rec)
-			err = txn.SetWithTTL([]byte(key), v, recttl)
-			c := zmqConfirmation{label: getTxLabel(rec.TxValue), duration: float64(rec.TxConfirmed - rec.TxIn)}
-			zmqConfirmationSet = append(zmqConfirmationSet, c)
+	return storeEntry{hash: tx.Hash, rec: rec}
+}
 
-		} else {
-			log.Debugf("BadgerDB get: Key(%s) not found", key)
-		}
-		return err
-	})
-	if err != nil {
-		if err != badger.ErrKeyNotFound {
-			log.Infof("BadgerDB error %v.", err)
-		}
+func (p *zmqPipeline) processConfirmedTx(tx *sn) {
+
+	rec, err := p.store.Confirm(tx.Hash, recordTime(time.Now()))
+	if err == errTxNotFound {
+		log.Debugf("Database get: Key(%s) not found", tx.Hash)
+		seen := p.zeroValue != nil && p.zeroValue.contains(tx.Hash)
+		p.groups.record(stoi(tx.Index), seen, 0, time.Now())
+		return
+	} else if err != nil {
+		log.Infof("Database error %v.", err)
+		return
 	}
-}
 
-func badgerDBCleanup(db *badger.DB) {
+	log.Infof("rec: %v.", *rec)
+	confirmedValue(rec)
+	p.groups.record(stoi(tx.Index), true, rec.TxValue, time.Now())
 
-	// Cleanup every 15 minutes
-	for {
-		time.Sleep(15 * time.Minute)
-		db.PurgeOlderVersions()
-		db.RunValueLogGC(0.5)
-		log.Info("BadgerDB purge.")
+	c := zmqConfirmation{label: getTxLabel(rec.TxValue), duration: recordDuration(rec.TxIn, rec.TxConfirmed), category: rec.Category}
+	if rec.MilestoneIn > 0 {
+		c.milestones = float64(stoi(tx.Index) - rec.MilestoneIn)
+		c.known = true
 	}
+	zmqConfirmationLock.Lock()
+	zmqConfirmationSet = append(zmqConfirmationSet, c)
+	zmqConfirmationLock.Unlock()
 }
 
-func initZmq(address *string) {
+func initZmq(e *exporter, address *string) {
 	major, minor, patch := zmq4.Version()
 	log.Infof("ZMQ version is %d.%d.%d", major, minor, patch)
 
-	go collectZmqAccums(address)
+	store, err := openTxStore(*databaseBackend)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	initLookup(e)
+	e.zmq = newZmqPipeline(store, e)
+	if *zmqCheckpointInterval > 0 {
+		if err := restoreZmqCheckpoint(e); err != nil {
+			log.Infof("Could not restore ZMQ accumulators: %v", err)
+		}
+	}
+	e.zmq.start(*zmqWorkers)
+	initCategories(e)
+	e.bundles = newBundleTracker(e, *zmqBundleMax, *zmqBundleTimeout)
+	if *tangleMaxTxs > 0 {
+		e.tangle = newApprovalGraph(e, *tangleMaxTxs, *tangleWindow)
+	}
+	initWatch(e)
+	initFinality(e)
+	initTop(e)
+	initConflicts(e)
+	initSpam(e)
+
+	if *zmqCheckpointInterval > 0 {
+		go checkpointZmq(e, *zmqCheckpointInterval)
+	}
+	go collectZmqAccums(address, e, nil)
+}
+
+// stopZmq finishes the queued database work, saves the accumulators unless
+// the checkpoint is disabled and closes the database.
+func stopZmq(e *exporter) {
+	e.zmq.stop()
+	if *zmqCheckpointInterval > 0 {
+		if err := saveZmqCheckpoint(e); err != nil {
+			log.Infof("Database error %v.", err)
+		}
+	}
+	if err := e.zmq.store.Close(); err != nil {
+		log.Infof("Database error %v.", err)
+	}
 }
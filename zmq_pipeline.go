@@ -0,0 +1,208 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"sync"
+	"time"
+)
+
+// zmqJob is a ZMQ message waiting for a worker to do its database work.
+type zmqJob struct {
+	received  time.Time
+	milestone int64
+	category  string
+	tx        *transaction
+	sn        *sn
+}
+
+func (j *zmqJob) topic() string {
+	if j.sn != nil {
+		return "sn"
+	}
+	return "tx"
+}
+
+// zmqPipeline moves the database work off the ZMQ receive loop. Messages are
+// queued on a bounded channel and handled by a fixed number of workers, which
+// write the records of new transactions in batches. When the queue is full
+// the overload policy decides whether messages are dropped or the receive
+// loop waits.
+type zmqPipeline struct {
+	store         txStore
+	queue         chan zmqJob
+	policy        string
+	batchSize     int
+	batchInterval time.Duration
+	dropped       *prometheus.CounterVec
+	lag           prometheus.Gauge
+	groups        *milestoneGroups
+	zeroValue     *seenFilter
+	writeDuration *prometheus.HistogramVec
+	wg            sync.WaitGroup
+
+	// handled is when the last message taken off the queue was received.
+	handledLock sync.Mutex
+	handled     time.Time
+
+	// stopping guards the queue against being closed while messages are
+	// still enqueued.
+	stopping sync.RWMutex
+	stopped  bool
+}
+
+func newZmqPipeline(store txStore, e *exporter) *zmqPipeline {
+	return &zmqPipeline{
+		store:         store,
+		queue:         make(chan zmqJob, *zmqQueueSize),
+		policy:        *zmqOverload,
+		batchSize:     *databaseBatchSize,
+		batchInterval: *databaseBatchInterval,
+		dropped:       e.iotaZmqDroppedMessages,
+		lag:           e.iotaZmqQueueLag,
+		groups:        e.milestoneGroups,
+		zeroValue:     e.zeroValue,
+		writeDuration: e.iotaZmqDBWriteDuration,
+		handled:       time.Now(),
+	}
+}
+
+func (p *zmqPipeline) start(workers int) {
+	for w := 0; w < workers; w++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+}
+
+// stop lets the workers finish the queued messages and waits for them.
+// Messages enqueued after stopping are ignored.
+func (p *zmqPipeline) stop() {
+	p.stopping.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.stopping.Unlock()
+	p.wg.Wait()
+}
+
+func (p *zmqPipeline) enqueue(job zmqJob) {
+	p.stopping.RLock()
+	defer p.stopping.RUnlock()
+	if p.stopped {
+		return
+	}
+
+	switch p.policy {
+	case "block":
+		p.queue <- job
+		return
+
+	case "drop-oldest":
+		for {
+			select {
+			case p.queue <- job:
+				return
+			default:
+			}
+			select {
+			case old := <-p.queue:
+				p.dropped.WithLabelValues(old.topic()).Inc()
+			default:
+			}
+		}
+	}
+
+	select {
+	case p.queue <- job:
+	default:
+		p.dropped.WithLabelValues(job.topic()).Inc()
+	}
+}
+
+func (p *zmqPipeline) worker() {
+	defer p.wg.Done()
+
+	batch := make([]storeEntry, 0, p.batchSize)
+	ticker := time.NewTicker(p.batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case job, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			p.handledLock.Lock()
+			p.handled = job.received
+			p.lag.Set(time.Since(job.received).Seconds())
+			p.handledLock.Unlock()
+
+			if job.tx != nil {
+				batch = append(batch, processValueTx(&job))
+				if len(batch) >= p.batchSize {
+					p.flush(batch)
+					batch = batch[:0]
+				}
+			} else if job.sn != nil {
+				start := time.Now()
+				p.processConfirmedTx(job.sn)
+				p.writeDuration.WithLabelValues("confirm").Observe(time.Since(start).Seconds())
+			}
+
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// observeLag updates the queue lag while messages are waiting. The workers
+// only set the lag when they take a message off the queue, so when they stall
+// the lag is the age of the last message handled, which every message still
+// queued was received after.
+func (p *zmqPipeline) observeLag(now time.Time) {
+	p.handledLock.Lock()
+	defer p.handledLock.Unlock()
+
+	if len(p.queue) > 0 {
+		p.lag.Set(now.Sub(p.handled).Seconds())
+	}
+}
+
+func (p *zmqPipeline) flush(batch []storeEntry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	start := time.Now()
+	if err := p.store.PutBatch(batch); err != nil {
+		log.Infof("Database error %v.", err)
+	}
+	p.writeDuration.WithLabelValues("put").Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,206 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syntheticHash returns an 81 tryte hash that is unique for n.
+func syntheticHash(prefix string, n int) string {
+	h := fmt.Sprintf("%s%d", prefix, n)
+	h = strings.NewReplacer("0", "A", "1", "B", "2", "C", "3", "D", "4", "E",
+		"5", "F", "6", "G", "7", "H", "8", "I", "9", "J").Replace(h)
+	return h + strings.Repeat("9", 81-len(h))
+}
+
+// syntheticTxMsg builds a tx message as published by IRI.
+func syntheticTxMsg(n int, value int64) string {
+	now := time.Now()
+	return fmt.Sprintf("tx %s %s %d %s %d 0 0 %s %s %s %d",
+		syntheticHash("TX", n), syntheticHash("ADDRESS", n%100), value, syntheticHash("TAG", 0)[:27],
+		now.Unix(), syntheticHash("BUNDLE", n), syntheticHash("TX", n-1), syntheticHash("TX", n-2), now.UnixNano()/1e6)
+}
+
+// syntheticSnMsg builds the sn message confirming the tx of syntheticTxMsg(n).
+func syntheticSnMsg(n int, milestone int64) string {
+	return fmt.Sprintf("sn %d %s %s %s %s %s", milestone,
+		syntheticHash("TX", n), syntheticHash("ADDRESS", n%100), syntheticHash("TX", n-1), syntheticHash("TX", n-2), syntheticHash("BUNDLE", n))
+}
+
+// newTestPipeline returns an exporter with a pipeline on store. The pipeline
+// flags are restored when the test ends.
+func newTestPipeline(tb testing.TB, store txStore, queueSize int, policy string) (*exporter, *zmqPipeline) {
+	queueSizeFlag, overloadFlag := *zmqQueueSize, *zmqOverload
+	batchSizeFlag, batchIntervalFlag := *databaseBatchSize, *databaseBatchInterval
+	tb.Cleanup(func() {
+		*zmqQueueSize, *zmqOverload = queueSizeFlag, overloadFlag
+		*databaseBatchSize, *databaseBatchInterval = batchSizeFlag, batchIntervalFlag
+	})
+
+	*zmqQueueSize = queueSize
+	*zmqOverload = policy
+	*databaseBatchSize = 100
+	*databaseBatchInterval = 10 * time.Millisecond
+
+	e := newExporter("")
+	e.zmq = newZmqPipeline(store, e)
+	return e, e.zmq
+}
+
+func TestZmqPipelineDropNewest(t *testing.T) {
+
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 2, "drop-newest")
+
+	// No workers are running, so only the first two messages fit.
+	for n := 0; n < 5; n++ {
+		handleZmqMessage(e, syntheticTxMsg(n, 0))
+	}
+
+	if d := testutil.ToFloat64(e.iotaZmqDroppedMessages.WithLabelValues("tx")); d != 3 {
+		t.Errorf("Expected 3 dropped tx messages, got %v", d)
+	}
+	if job := <-p.queue; job.tx.Hash != syntheticHash("TX", 0) {
+		t.Errorf("Expected the oldest message to be kept, got %v", job.tx.Hash)
+	}
+}
+
+func TestZmqPipelineDropOldest(t *testing.T) {
+
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 2, "drop-oldest")
+
+	for n := 0; n < 5; n++ {
+		handleZmqMessage(e, syntheticTxMsg(n, 0))
+	}
+
+	if d := testutil.ToFloat64(e.iotaZmqDroppedMessages.WithLabelValues("tx")); d != 3 {
+		t.Errorf("Expected 3 dropped tx messages, got %v", d)
+	}
+	if job := <-p.queue; job.tx.Hash != syntheticHash("TX", 3) {
+		t.Errorf("Expected the newest messages to be kept, got %v", job.tx.Hash)
+	}
+}
+
+func TestZmqPipelineConfirm(t *testing.T) {
+
+	store := newMemoryStore(1000, testRetention)
+	e, p := newTestPipeline(t, store, 100, "block")
+	p.start(2)
+
+	handleZmqMessage(e, syntheticTxMsg(1, 10))
+	// Wait for the batch to be written
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, err := store.Get(syntheticHash("TX", 1)); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Expected the tx to be written, got %v", err)
+		}
+	}
+	handleZmqMessage(e, syntheticSnMsg(1, 400001))
+	p.stop()
+
+	zmqConfirmationLock.Lock()
+	defer zmqConfirmationLock.Unlock()
+	if len(zmqConfirmationSet) != 1 || zmqConfirmationSet[0].label != "<> 0" {
+		t.Errorf("Expected one confirmation of a value tx, got %v", zmqConfirmationSet)
+	}
+	zmqConfirmationSet = nil
+}
+
+func benchmarkZmqPipeline(b *testing.B, store txStore) {
+
+	e, p := newTestPipeline(b, store, 10000, "block")
+	p.start(2)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		handleZmqMessage(e, syntheticTxMsg(n, int64(n%10)))
+		if n%10 == 9 {
+			handleZmqMessage(e, syntheticSnMsg(n-5, int64(400000+n/1000)))
+		}
+	}
+	p.stop()
+}
+
+func BenchmarkZmqPipelineMemory(b *testing.B) {
+	store := newMemoryStore(100000, testRetention)
+	defer store.Close()
+	benchmarkZmqPipeline(b, store)
+}
+
+func BenchmarkZmqPipelineBadger(b *testing.B) {
+	dir, err := ioutil.TempDir("", "iotabadgerdb")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := openBadgerStore(dir, testRetention)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+	benchmarkZmqPipeline(b, store)
+}
+
+func TestZmqConfirmSameMilestone(t *testing.T) {
+
+	store := newMemoryStore(1000, testRetention)
+	hash := syntheticHash("TX", 1)
+	store.Put(hash, &txRecord{TxIn: recordTime(time.Now()), TxValue: 10, MilestoneIn: 400001})
+	_, p := newTestPipeline(t, store, 10, "block")
+	p.processConfirmedTx(&sn{Index: "400001", Hash: hash})
+
+	zmqConfirmationLock.Lock()
+	defer zmqConfirmationLock.Unlock()
+	if len(zmqConfirmationSet) != 1 || !zmqConfirmationSet[0].known || zmqConfirmationSet[0].milestones != 0 {
+		t.Errorf("Expected a confirmation within the arrival milestone, got %v", zmqConfirmationSet)
+	}
+	zmqConfirmationSet = nil
+}
+
+func TestZmqPipelineStalledLag(t *testing.T) {
+
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 10, "drop-newest")
+
+	// Nothing is queued, so there is no lag.
+	p.observeLag(time.Now().Add(time.Minute))
+	if lag := testutil.ToFloat64(e.iotaZmqQueueLag); lag != 0 {
+		t.Errorf("Test empty: Expected no lag, got %v", lag)
+	}
+
+	// No workers are running, so the lag keeps growing while the message waits.
+	handleZmqMessage(e, syntheticTxMsg(0, 0))
+	p.observeLag(time.Now().Add(time.Minute))
+	if lag := testutil.ToFloat64(e.iotaZmqQueueLag); lag < 60 {
+		t.Errorf("Test stalled: Expected a lag of at least 60s, got %v", lag)
+	}
+}
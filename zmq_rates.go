@@ -0,0 +1,180 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+// rateWindow is a sliding window the ZMQ rates are computed over.
+type rateWindow struct {
+	label    string
+	duration time.Duration
+}
+
+var zmqRateWindows = []rateWindow{
+	{label: "1m", duration: time.Minute},
+	{label: "5m", duration: 5 * time.Minute},
+	{label: "1h", duration: time.Hour},
+}
+
+// rateCounter counts events per second over a fixed span, so the number of
+// events in any window up to that span can be summed.
+type rateCounter struct {
+	sync.Mutex
+	slots []float64
+	last  int64
+	start time.Time
+}
+
+func newRateCounter(span time.Duration, now time.Time) *rateCounter {
+	return &rateCounter{
+		slots: make([]float64, int(span/time.Second)),
+		last:  now.Unix(),
+		start: now,
+	}
+}
+
+// advance clears the slots of the seconds that passed since the last event.
+func (r *rateCounter) advance(now time.Time) {
+	sec := now.Unix()
+	for s := r.last + 1; s <= sec && s <= r.last+int64(len(r.slots)); s++ {
+		r.slots[s%int64(len(r.slots))] = 0
+	}
+	if sec > r.last {
+		r.last = sec
+	}
+}
+
+func (r *rateCounter) add(now time.Time, n float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.advance(now)
+	if sec := now.Unix(); sec > r.last-int64(len(r.slots)) {
+		r.slots[sec%int64(len(r.slots))] += n
+	}
+}
+
+// sum returns the events in the window ending now, and the length of the
+// window in seconds, which is shorter than asked when the counter is younger.
+func (r *rateCounter) sum(now time.Time, window time.Duration) (float64, float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.advance(now)
+	n := int64(window / time.Second)
+	if n > int64(len(r.slots)) {
+		n = int64(len(r.slots))
+	}
+	if age := int64(now.Sub(r.start)/time.Second) + 1; age < n {
+		n = age
+	}
+
+	total := 0.0
+	for s := r.last - n + 1; s <= r.last; s++ {
+		total += r.slots[s%int64(len(r.slots))]
+	}
+	return total, float64(n)
+}
+
+// rate returns the events per second in the window ending now.
+func (r *rateCounter) rate(now time.Time, window time.Duration) float64 {
+	total, seconds := r.sum(now, window)
+	if seconds <= 0 {
+		return 0
+	}
+	return total / seconds
+}
+
+var zmqSeenRate = newRateCounter(time.Hour, time.Now())
+var zmqConfirmedRate = newRateCounter(time.Hour, time.Now())
+
+func metricsZmqRates(e *exporter) {
+
+	e.iotaZmqTPS = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_tps",
+			Name: "iota_zmq_tps",
+			Help: "Transactions per second seen by zeroMQ over the window.",
+		},
+		[]string{"window"},
+	)
+
+	e.iotaZmqCTPS = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_ctps",
+			Name: "iota_zmq_ctps",
+			Help: "Confirmed transactions per second seen by zeroMQ over the window.",
+		},
+		[]string{"window"},
+	)
+
+	e.iotaZmqConfirmationRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			//Namespace: namespace,
+			//Subsystem: "zmq",
+			//Name: "zmq_confirmation_rate",
+			Name: "iota_zmq_confirmation_rate",
+			Help: "Confirmed transactions divided by seen transactions over the window.",
+		},
+		[]string{"window"},
+	)
+}
+
+func describeZmqRates(e *exporter, ch chan<- *prometheus.Desc) {
+	e.iotaZmqTPS.Describe(ch)
+	e.iotaZmqCTPS.Describe(ch)
+	e.iotaZmqConfirmationRate.Describe(ch)
+}
+
+func collectZmqRates(e *exporter, ch chan<- prometheus.Metric) {
+	e.iotaZmqTPS.Collect(ch)
+	e.iotaZmqCTPS.Collect(ch)
+	e.iotaZmqConfirmationRate.Collect(ch)
+}
+
+func scrapeZmqRates(e *exporter) {
+	now := time.Now()
+
+	for _, w := range zmqRateWindows {
+		tps := zmqSeenRate.rate(now, w.duration)
+		ctps := zmqConfirmedRate.rate(now, w.duration)
+
+		e.iotaZmqTPS.WithLabelValues(w.label).Set(tps)
+		e.iotaZmqCTPS.WithLabelValues(w.label).Set(ctps)
+		if tps > 0 {
+			e.iotaZmqConfirmationRate.WithLabelValues(w.label).Set(ctps / tps)
+		} else {
+			e.iotaZmqConfirmationRate.WithLabelValues(w.label).Set(0)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+	"time"
+)
+
+func TestRateCounter(t *testing.T) {
+
+	start := time.Date(2018, 3, 4, 12, 0, 0, 0, time.UTC)
+	r := newRateCounter(time.Hour, start)
+
+	// 10 tx per second for two minutes
+	for s := 0; s < 120; s++ {
+		r.add(start.Add(time.Duration(s)*time.Second), 10)
+	}
+	now := start.Add(119 * time.Second)
+
+	if tps := r.rate(now, time.Minute); tps != 10 {
+		t.Errorf("Expected 10 tps over 1m, got %v", tps)
+	}
+	// The counter is only two minutes old, so the hour window is shortened
+	if tps := r.rate(now, time.Hour); tps != 10 {
+		t.Errorf("Expected 10 tps over 1h, got %v", tps)
+	}
+
+	// A minute later without traffic the 1m window is empty
+	now = now.Add(time.Minute)
+	if tps := r.rate(now, time.Minute); tps != 0 {
+		t.Errorf("Expected 0 tps over 1m, got %v", tps)
+	}
+	if tps := r.rate(now, 5*time.Minute); tps != 1200.0/180 {
+		t.Errorf("Expected %v tps over 5m, got %v", 1200.0/180, tps)
+	}
+
+	// After more than the span everything has slid out
+	now = now.Add(2 * time.Hour)
+	if total, _ := r.sum(now, time.Hour); total != 0 {
+		t.Errorf("Expected an empty counter, got %v", total)
+	}
+}
+
+func TestCollectZmqRatesGolden(t *testing.T) {
+
+	defer func(seen, confirmed *rateCounter) {
+		zmqSeenRate, zmqConfirmedRate = seen, confirmed
+	}(zmqSeenRate, zmqConfirmedRate)
+
+	// The counters are older than every window, so the rates do not depend
+	// on when the test runs.
+	now := time.Now()
+	zmqSeenRate = newRateCounter(time.Hour, now.Add(-2*time.Hour))
+	zmqConfirmedRate = newRateCounter(time.Hour, now.Add(-2*time.Hour))
+	zmqSeenRate.add(now.Add(-3*time.Minute), 300)
+	zmqSeenRate.add(now.Add(-30*time.Second), 60)
+	zmqConfirmedRate.add(now.Add(-30*time.Second), 30)
+
+	e := newExporter("")
+	scrapeZmqRates(e)
+	checkGolden(t, "zmq_rates", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectZmqRates(e, ch)
+	})))
+}
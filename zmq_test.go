@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright (c) 2018 Marcel van Eck
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+	"time"
+)
+
+func TestCollectZmqAccums(t *testing.T) {
+
+	publisher := newFakeZmqPublisher(t)
+	defer publisher.Close()
+
+	defer func(timeout time.Duration) { zmqReceiveTimeout = timeout }(zmqReceiveTimeout)
+	zmqReceiveTimeout = 100 * time.Millisecond
+
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 1000, "drop-newest")
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		collectZmqAccums(&publisher.address, e, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("Expected the ZMQ receiver to stop")
+		}
+	}()
+
+	// A subscriber misses what is published before it connected, so publish
+	// a probe until one arrives. Probes still in flight are skipped below.
+	probe := syntheticTxMsg(-1, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(p.queue) == 0 && time.Now().Before(deadline) {
+		if err := publisher.publish(probe); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(p.queue) == 0 {
+		t.Fatalf("Expected the probe to be received")
+	}
+
+	for n := 0; n < 10; n++ {
+		if err := publisher.publish(syntheticTxMsg(n, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := map[string]bool{}
+	deadline = time.Now().Add(5 * time.Second)
+	for len(received) < 10 && time.Now().Before(deadline) {
+		select {
+		case job := <-p.queue:
+			if job.tx.Hash != syntheticHash("TX", -1) {
+				received[job.tx.Hash] = true
+			}
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for n := 0; n < 10; n++ {
+		if !received[syntheticHash("TX", n)] {
+			t.Errorf("Test %v: Expected the tx message to be received", n)
+		}
+	}
+}
+
+func TestCollectZmqGolden(t *testing.T) {
+
+	zmqAccumsLock.Lock()
+	saved := zmqAccums
+	zmqAccums = zmqAccumsf{}
+	zmqAccumsLock.Unlock()
+	defer func() {
+		zmqAccumsLock.Lock()
+		zmqAccums = saved
+		zmqAccumsLock.Unlock()
+	}()
+
+	// No workers run, so the queued messages are discarded and the
+	// confirmations the sn messages would produce are added with fixed
+	// durations. An empty queue keeps the lag at 0.
+	e, p := newTestPipeline(t, newMemoryStore(1000, testRetention), 100, "drop-newest")
+	for n := 0; n < 5; n++ {
+		handleZmqMessage(e, syntheticTxMsg(n, int64(n%2)*100))
+	}
+	handleZmqMessage(e, syntheticSnMsg(0, 400001))
+	handleZmqMessage(e, syntheticSnMsg(1, 400001))
+	handleZmqMessage(e, "rstat 10 20 30 40 50")
+	for len(p.queue) > 0 {
+		<-p.queue
+	}
+
+	zmqConfirmationLock.Lock()
+	zmqConfirmationSet = []zmqConfirmation{
+		{label: "0", duration: 240, milestones: 0, known: true},
+		{label: "<> 0", duration: 900, milestones: 2, known: true},
+	}
+	zmqConfirmationLock.Unlock()
+
+	scrapeZmq(e)
+	checkGolden(t, "zmq", exposition(t, collectorFunc(func(ch chan<- prometheus.Metric) {
+		collectZmq(e, ch)
+	})))
+}